@@ -0,0 +1,232 @@
+// Package builder composes simple SELECT statements for the structs that
+// meddler maps, e.g.
+//
+//	b := builder.New(meddler.PostgreSQL)
+//	err := b.Select(&Person{}).From("person").Where("age > ?", 30).OrderBy("name").Limit(10).All(db, &people)
+//
+// The column list comes from the struct, table names are quoted, and ?
+// placeholders are numbered for the database. The results are scanned
+// the same way as meddler.QueryAll and meddler.QueryRow. If the struct
+// has a softdelete field, rows that have been soft deleted are skipped,
+// as they are by meddler.Load; call WithDeleted to include them.
+//
+// It is optional: anything it builds can also be written by hand.
+package builder
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/russross/meddler"
+)
+
+// Builder starts queries for a meddler Database.
+type Builder struct {
+	d *meddler.Database
+}
+
+// New returns a Builder whose queries use the SQL syntax of d.
+func New(d *meddler.Database) *Builder {
+	return &Builder{d: d}
+}
+
+// Select starts a query for the columns of model, which is a pointer to
+// a struct; its fields are not read.
+func (b *Builder) Select(model interface{}) *Query {
+	q := &Query{d: b.d, limit: -1}
+	q.columns, q.err = b.d.ColumnsQuoted(model, true)
+	if q.err == nil {
+		q.live, q.liveArgs, q.err = b.d.SoftDeleteFilter(model)
+	}
+	return q
+}
+
+// Select using the Default Database type
+func Select(model interface{}) *Query {
+	return New(meddler.Default).Select(model)
+}
+
+// Query is a SELECT statement under construction. Each method returns a
+// new Query and leaves the one it was called on unchanged, so a partial
+// query can be reused as the base of several others.
+type Query struct {
+	d           *meddler.Database
+	columns     string
+	table       string
+	where       []string
+	args        []interface{}
+	live        string // the condition that skips soft deleted rows, if any
+	liveArgs    []interface{}
+	withDeleted bool
+	orderBy     []string
+	limit       int
+	offset      int
+	lock        string
+	err         error
+}
+
+func (q *Query) clone() *Query {
+	c := *q
+	c.where = append([]string(nil), q.where...)
+	c.args = append([]interface{}(nil), q.args...)
+	c.orderBy = append([]string(nil), q.orderBy...)
+	return &c
+}
+
+// From sets the table to select from. The name is quoted for the
+// database, with each part of a dotted name quoted separately, and is
+// checked if the Database has StrictIdentifiers set.
+func (q *Query) From(table string) *Query {
+	c := q.clone()
+	c.table = table
+	return c
+}
+
+// Where adds a condition, written with ? placeholders for args. Several
+// conditions are joined with AND. Arguments wrapped with meddler.In are
+// expanded as they are for meddler.QueryAll.
+func (q *Query) Where(cond string, args ...interface{}) *Query {
+	c := q.clone()
+	c.where = append(c.where, cond)
+	c.args = append(c.args, args...)
+	return c
+}
+
+// WithDeleted includes rows that have been soft deleted, which are
+// otherwise skipped if the struct has a softdelete field.
+func (q *Query) WithDeleted() *Query {
+	c := q.clone()
+	c.withDeleted = true
+	return c
+}
+
+// OrderBy adds terms to the ORDER BY clause, e.g. "name" or "age DESC".
+// They are used as they are, without quoting.
+func (q *Query) OrderBy(terms ...string) *Query {
+	c := q.clone()
+	c.orderBy = append(c.orderBy, terms...)
+	return c
+}
+
+// Limit sets the maximum number of rows returned. A negative limit means
+// no limit.
+func (q *Query) Limit(limit int) *Query {
+	c := q.clone()
+	c.limit = limit
+	return c
+}
+
+// Offset sets the number of rows to skip.
+func (q *Query) Offset(offset int) *Query {
+	c := q.clone()
+	c.offset = offset
+	return c
+}
+
+// Lock locks the selected rows, if the database supports it.
+func (q *Query) Lock(mode meddler.LockMode) *Query {
+	c := q.clone()
+	c.lock = q.d.LockClause(mode)
+	return c
+}
+
+// SQL returns the statement and its arguments, ready to run: arguments
+// wrapped with meddler.In are expanded, and the placeholders are numbered
+// for the database.
+func (q *Query) SQL() (string, []interface{}, error) {
+	if q.err != nil {
+		return "", nil, q.err
+	}
+	if q.table == "" {
+		return "", nil, errors.New("meddler/builder: no table given; call From")
+	}
+	if err := q.d.CheckIdentifier(q.table); err != nil {
+		return "", nil, err
+	}
+
+	where, args := q.where, q.args
+	if q.live != "" && !q.withDeleted {
+		where = append(where[:len(where):len(where)], q.live)
+		args = append(args[:len(args):len(args)], q.liveArgs...)
+	}
+
+	var b strings.Builder
+	b.WriteString("SELECT ")
+	b.WriteString(q.columns)
+	b.WriteString(" FROM ")
+	b.WriteString(q.d.QuoteTable(q.table))
+	for i, cond := range where {
+		if i == 0 {
+			b.WriteString(" WHERE ")
+		} else {
+			b.WriteString(" AND ")
+		}
+		if len(where) > 1 {
+			b.WriteString("(" + cond + ")")
+		} else {
+			b.WriteString(cond)
+		}
+	}
+	orderBy := q.orderBy
+	limit := q.d.LimitOffset(q.limit, q.offset)
+	if ordered, ok := q.d.Dialect.(meddler.OrderedLimitDialect); ok && len(orderBy) == 0 && limit != "" {
+		// the database only accepts the limit after an ORDER BY
+		orderBy = []string{ordered.LimitOrderBy()}
+	}
+	if len(orderBy) > 0 {
+		b.WriteString(" ORDER BY ")
+		b.WriteString(strings.Join(orderBy, ", "))
+	}
+	b.WriteString(limit)
+	b.WriteString(q.lock)
+
+	query, args := q.d.ExpandIn(b.String(), args...)
+	return q.d.Rebind(query), args, nil
+}
+
+// runner returns the Database that runs the query. The placeholders from
+// SQL are already numbered, so they must not be rebound again.
+func (q *Query) runner() *meddler.Database {
+	d := *q.d
+	d.AutoRebind = false
+	return &d
+}
+
+// All runs the query and scans all result rows into dst, which is a
+// pointer to a slice of struct pointers.
+func (q *Query) All(db meddler.DB, dst interface{}) error {
+	query, args, err := q.SQL()
+	if err != nil {
+		return err
+	}
+	return q.runner().QueryAll(db, dst, query, args...)
+}
+
+// AllContext is the context-aware version of All.
+func (q *Query) AllContext(ctx context.Context, db meddler.DBContext, dst interface{}) error {
+	query, args, err := q.SQL()
+	if err != nil {
+		return err
+	}
+	return q.runner().QueryAllContext(ctx, db, dst, query, args...)
+}
+
+// One runs the query and scans a single row into dst, which is a pointer
+// to a struct. It returns sql.ErrNoRows if there are no results.
+func (q *Query) One(db meddler.DB, dst interface{}) error {
+	query, args, err := q.SQL()
+	if err != nil {
+		return err
+	}
+	return q.runner().QueryRow(db, dst, query, args...)
+}
+
+// OneContext is the context-aware version of One.
+func (q *Query) OneContext(ctx context.Context, db meddler.DBContext, dst interface{}) error {
+	query, args, err := q.SQL()
+	if err != nil {
+		return err
+	}
+	return q.runner().QueryRowContext(ctx, db, dst, query, args...)
+}
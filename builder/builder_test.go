@@ -0,0 +1,135 @@
+package builder
+
+import (
+	"database/sql"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/russross/meddler"
+)
+
+type Person struct {
+	ID   int64  `meddler:"id,pk"`
+	Name string `meddler:"name"`
+	Age  int    `meddler:"age"`
+}
+
+type Account struct {
+	ID        int64      `meddler:"id,pk"`
+	DeletedAt *time.Time `meddler:"deleted_at,softdelete"`
+}
+
+func TestSQL(t *testing.T) {
+	tests := []struct {
+		d        *meddler.Database
+		q        func(b *Builder) *Query
+		expected string
+		args     []interface{}
+	}{
+		{meddler.PostgreSQL, func(b *Builder) *Query {
+			return b.Select(&Person{}).From("person").Where("age > ?", 30).OrderBy("name").Limit(10)
+		}, `SELECT "id","name","age" FROM "person" WHERE age > $1 ORDER BY name LIMIT 10`, []interface{}{30}},
+		{meddler.PostgreSQL, func(b *Builder) *Query {
+			return b.Select(&Person{}).From("app.person").Where("age > ? OR name = '?'", 30).Where("name <> ?", "x").Offset(5).Lock(meddler.LockForUpdate)
+		}, `SELECT "id","name","age" FROM "app"."person" WHERE (age > $1 OR name = '?') AND (name <> $2) OFFSET 5 FOR UPDATE`, []interface{}{30, "x"}},
+		{meddler.MySQL, func(b *Builder) *Query {
+			return b.Select(&Person{}).From("person").OrderBy("age DESC", "name").Limit(3).Offset(6)
+		}, "SELECT `id`,`name`,`age` FROM `person` ORDER BY age DESC, name LIMIT 3 OFFSET 6", nil},
+		{meddler.SQLServer, func(b *Builder) *Query {
+			return b.Select(&Person{}).From("person").Where("age = ?", 1).OrderBy("id").Limit(2)
+		}, "SELECT [id],[name],[age] FROM [person] WHERE age = @p1 ORDER BY id OFFSET 0 ROWS FETCH NEXT 2 ROWS ONLY", []interface{}{1}},
+		{meddler.SQLServer, func(b *Builder) *Query {
+			return b.Select(&Person{}).From("person").Limit(2)
+		}, "SELECT [id],[name],[age] FROM [person] ORDER BY (SELECT NULL) OFFSET 0 ROWS FETCH NEXT 2 ROWS ONLY", nil},
+		{meddler.PostgreSQL, func(b *Builder) *Query {
+			return b.Select(&Person{}).From("person").Where("id IN (?) AND age > ?", meddler.In([]int{1, 2}), 30)
+		}, `SELECT "id","name","age" FROM "person" WHERE id IN ($1,$2) AND age > $3`, []interface{}{1, 2, 30}},
+		{meddler.PostgreSQL, func(b *Builder) *Query {
+			return b.Select(&Account{}).From("account").Where("id > ?", 5)
+		}, `SELECT "id","deleted_at" FROM "account" WHERE (id > $1) AND ("deleted_at" IS NULL)`, []interface{}{5}},
+		{meddler.PostgreSQL, func(b *Builder) *Query {
+			return b.Select(&Account{}).From("account").WithDeleted()
+		}, `SELECT "id","deleted_at" FROM "account"`, nil},
+	}
+	for i, test := range tests {
+		q, args, err := test.q(New(test.d)).SQL()
+		if err != nil {
+			t.Errorf("%d: SQL error: %v", i, err)
+			continue
+		}
+		if q != test.expected {
+			t.Errorf("%d: expected %q, found %q", i, test.expected, q)
+		}
+		if !reflect.DeepEqual(args, test.args) {
+			t.Errorf("%d: expected args %v, found %v", i, test.args, args)
+		}
+	}
+
+	// queries are not changed by the queries built from them
+	base := New(meddler.PostgreSQL).Select(&Person{}).From("person").Where("age > ?", 30)
+	base.Where("name = ?", "x")
+	if q, args, _ := base.SQL(); q != `SELECT "id","name","age" FROM "person" WHERE age > $1` || len(args) != 1 {
+		t.Errorf("base query was changed: found %q with %v", q, args)
+	}
+
+	if _, _, err := New(meddler.PostgreSQL).Select(&Person{}).SQL(); err == nil {
+		t.Errorf("SQL without a table: expected err, got nil")
+	}
+	if _, _, err := New(meddler.PostgreSQL).Select(5).From("person").SQL(); err == nil {
+		t.Errorf("SQL with an int model: expected err, got nil")
+	}
+
+	strict := *meddler.PostgreSQL
+	strict.StrictIdentifiers = true
+	if _, _, err := New(&strict).Select(&Person{}).From(`person"; --`).SQL(); !errors.Is(err, meddler.ErrInvalidIdentifier) {
+		t.Errorf("SQL with a bad table name: expected ErrInvalidIdentifier, got %v", err)
+	}
+}
+
+func TestQueries(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("error creating test database: %v", err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec("create table person (id integer primary key, name text not null, age integer not null)"); err != nil {
+		t.Fatalf("error creating person table: %v", err)
+	}
+	for i, name := range []string{"Alice", "Bob", "Carol", "Dave"} {
+		if err := meddler.SQLite.Insert(db, "person", &Person{Name: name, Age: 20 + 10*i}); err != nil {
+			t.Fatalf("Insert error: %v", err)
+		}
+	}
+
+	b := New(meddler.SQLite)
+	var people []*Person
+	if err := b.Select(&Person{}).From("person").Where("age > ?", 25).OrderBy("name DESC").Limit(2).All(db, &people); err != nil {
+		t.Fatalf("All error: %v", err)
+	}
+	if len(people) != 2 || people[0].Name != "Dave" || people[1].Name != "Carol" {
+		t.Errorf("All: expected Dave and Carol, found %v", people)
+	}
+
+	people = nil
+	if err := b.Select(&Person{}).From("person").Where("name in (?)", meddler.In([]string{"Alice", "Bob"})).OrderBy("id").All(db, &people); err != nil {
+		t.Fatalf("All with In error: %v", err)
+	}
+	if len(people) != 2 || people[0].Name != "Alice" || people[1].Name != "Bob" {
+		t.Errorf("All with In: expected Alice and Bob, found %v", people)
+	}
+
+	p := new(Person)
+	if err := b.Select(p).From("person").Where("name = ?", "Bob").One(db, p); err != nil {
+		t.Fatalf("One error: %v", err)
+	}
+	if p.Name != "Bob" || p.Age != 30 {
+		t.Errorf("One: expected Bob aged 30, found %s aged %d", p.Name, p.Age)
+	}
+	if err := b.Select(p).From("person").Where("name = ?", "Eve").One(db, p); err != sql.ErrNoRows {
+		t.Errorf("One with no rows: expected sql.ErrNoRows, found %v", err)
+	}
+}
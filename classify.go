@@ -0,0 +1,442 @@
+package meddler
+
+import (
+	"errors"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// ErrorKind is the kind of database error reported by an ErrorClassifier.
+type ErrorKind int
+
+const (
+	// UnknownError is any error that is not recognized.
+	UnknownError ErrorKind = iota
+
+	// UniqueViolation is a duplicate value in a unique index or primary key.
+	UniqueViolation
+
+	// ForeignKeyViolation is a missing or still referenced row.
+	ForeignKeyViolation
+
+	// NotNullViolation is a NULL written to a NOT NULL column.
+	NotNullViolation
+
+	// CheckViolation is a value rejected by a CHECK constraint.
+	CheckViolation
+
+	// Deadlock means the transaction was chosen as a deadlock victim,
+	// and can be retried.
+	Deadlock
+
+	// SerializationFailure means the transaction conflicted with another
+	// one under serializable isolation, and can be retried.
+	SerializationFailure
+)
+
+// ErrorClassifier reports the kind of a database driver error, along with
+// the name of the constraint or column involved if the driver gives one.
+// It is given each error in the chain of the original error in turn, so it
+// only needs to recognize the error types of the driver it handles, and
+// return UnknownError for everything else.
+type ErrorClassifier func(err error) (kind ErrorKind, name string)
+
+// builtinClassifiers are the classifiers for every supported driver.
+var builtinClassifiers = []ErrorClassifier{ClassifySQLite, ClassifySQLServer, ClassifyMySQL, ClassifyPostgreSQL, ClassifyOracle}
+
+// ClassifyError reports the kind of err using the ErrorClassifier of the
+// database, looking through wrapped errors such as *QueryError. If the
+// database has no classifier, the built-in ones for every supported driver
+// are tried.
+func (d *Database) ClassifyError(err error) (ErrorKind, string) {
+	if d.Classifier == nil {
+		return classifyError(err, builtinClassifiers)
+	}
+	return classifyError(err, []ErrorClassifier{d.Classifier})
+}
+
+// ClassifyError using the Default Database type. Since the error may come
+// from any driver, the built-in classifiers for every supported driver are
+// tried after the one of the Default Database type.
+func ClassifyError(err error) (ErrorKind, string) {
+	classifiers := builtinClassifiers
+	if Default.Classifier != nil {
+		classifiers = append([]ErrorClassifier{Default.Classifier}, builtinClassifiers...)
+	}
+	return classifyError(err, classifiers)
+}
+
+func classifyError(err error, classifiers []ErrorClassifier) (ErrorKind, string) {
+	for ; err != nil; err = errors.Unwrap(err) {
+		for _, classify := range classifiers {
+			if kind, name := classify(err); kind != UnknownError {
+				return kind, name
+			}
+		}
+	}
+	return UnknownError, ""
+}
+
+func isKind(classify func(error) (ErrorKind, string), err error, kind ErrorKind) (string, bool) {
+	found, name := classify(err)
+	if found != kind {
+		return "", false
+	}
+	return name, true
+}
+
+// IsUniqueViolation reports whether err is a unique constraint violation,
+// along with the name of the index or columns if the driver gives it.
+func (d *Database) IsUniqueViolation(err error) (string, bool) {
+	return isKind(d.ClassifyError, err, UniqueViolation)
+}
+
+// IsForeignKeyViolation reports whether err is a foreign key violation,
+// along with the name of the constraint if the driver gives it.
+func (d *Database) IsForeignKeyViolation(err error) (string, bool) {
+	return isKind(d.ClassifyError, err, ForeignKeyViolation)
+}
+
+// IsNotNullViolation reports whether err is a NOT NULL violation,
+// along with the name of the column if the driver gives it.
+func (d *Database) IsNotNullViolation(err error) (string, bool) {
+	return isKind(d.ClassifyError, err, NotNullViolation)
+}
+
+// IsCheckViolation reports whether err is a CHECK constraint violation,
+// along with the name of the constraint if the driver gives it.
+func (d *Database) IsCheckViolation(err error) (string, bool) {
+	return isKind(d.ClassifyError, err, CheckViolation)
+}
+
+// IsDeadlock reports whether err means the transaction was a deadlock victim.
+func (d *Database) IsDeadlock(err error) (string, bool) {
+	return isKind(d.ClassifyError, err, Deadlock)
+}
+
+// IsSerializationFailure reports whether err means the transaction could
+// not be serialized with a concurrent one.
+func (d *Database) IsSerializationFailure(err error) (string, bool) {
+	return isKind(d.ClassifyError, err, SerializationFailure)
+}
+
+// IsUniqueViolation using the Default Database type. Like ClassifyError,
+// it tries the built-in classifiers for every supported driver.
+func IsUniqueViolation(err error) (string, bool) {
+	return isKind(ClassifyError, err, UniqueViolation)
+}
+
+// IsForeignKeyViolation using the Default Database type
+func IsForeignKeyViolation(err error) (string, bool) {
+	return isKind(ClassifyError, err, ForeignKeyViolation)
+}
+
+// IsNotNullViolation using the Default Database type
+func IsNotNullViolation(err error) (string, bool) {
+	return isKind(ClassifyError, err, NotNullViolation)
+}
+
+// IsCheckViolation using the Default Database type
+func IsCheckViolation(err error) (string, bool) {
+	return isKind(ClassifyError, err, CheckViolation)
+}
+
+// IsDeadlock using the Default Database type
+func IsDeadlock(err error) (string, bool) {
+	return isKind(ClassifyError, err, Deadlock)
+}
+
+// IsSerializationFailure using the Default Database type
+func IsSerializationFailure(err error) (string, bool) {
+	return isKind(ClassifyError, err, SerializationFailure)
+}
+
+// errorType reports whether err is, or points to, one of the given types,
+// each named by its package path and type name, e.g. "github.com/lib/pq.Error".
+func errorType(err error, types []string) bool {
+	t := reflect.TypeOf(err)
+	if t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil {
+		return false
+	}
+	name := t.PkgPath() + "." + t.Name()
+	for _, s := range types {
+		if s == name {
+			return true
+		}
+	}
+	return false
+}
+
+// errorField returns the named field of an error struct, or of the struct
+// it points to, or an invalid Value if there is no such field.
+func errorField(err error, name string) reflect.Value {
+	v := reflect.ValueOf(err)
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return reflect.Value{}
+	}
+	return v.FieldByName(name)
+}
+
+// errorInt returns the named integer field of an error struct.
+func errorInt(err error, name string) (int64, bool) {
+	f := errorField(err, name)
+	switch f.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return f.Int(), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(f.Uint()), true
+	}
+	return 0, false
+}
+
+// errorString returns the named string field of an error struct.
+func errorString(err error, name string) (string, bool) {
+	f := errorField(err, name)
+	if f.Kind() != reflect.String {
+		return "", false
+	}
+	return f.String(), true
+}
+
+// SQLite extended result codes
+const (
+	sqliteCheck          = 275
+	sqliteBusySnapshot   = 517
+	sqliteForeignKey     = 787
+	sqliteNotNull        = 1299
+	sqlitePrimaryKey     = 1555
+	sqliteUnique         = 2067
+	sqliteConstraintCode = 19
+)
+
+// ClassifySQLite is the ErrorClassifier for github.com/mattn/go-sqlite3,
+// which reports the extended result code of the error. For constraint
+// violations, the name is taken from the message, e.g. "tag.name" from
+// "UNIQUE constraint failed: tag.name".
+func ClassifySQLite(err error) (ErrorKind, string) {
+	code, ok := errorInt(err, "Code")
+	if !ok {
+		return UnknownError, ""
+	}
+	extended, ok := errorInt(err, "ExtendedCode")
+	if !ok {
+		return UnknownError, ""
+	}
+
+	name := ""
+	if code == sqliteConstraintCode {
+		if i := strings.Index(err.Error(), " constraint failed: "); i >= 0 {
+			name = err.Error()[i+len(" constraint failed: "):]
+		}
+	}
+
+	switch extended {
+	case sqliteUnique, sqlitePrimaryKey:
+		return UniqueViolation, name
+	case sqliteForeignKey:
+		return ForeignKeyViolation, name
+	case sqliteNotNull:
+		return NotNullViolation, name
+	case sqliteCheck:
+		return CheckViolation, name
+	case sqliteBusySnapshot:
+		return SerializationFailure, ""
+	}
+	return UnknownError, ""
+}
+
+var (
+	mysqlDuplicateKey = regexp.MustCompile(`for key '([^']*)'`)
+	mysqlConstraint   = regexp.MustCompile("CONSTRAINT `([^`]*)`")
+	mysqlColumn       = regexp.MustCompile(`Column '([^']*)'`)
+	mysqlCheck        = regexp.MustCompile(`[Cc]heck constraint '([^']*)'`)
+)
+
+// ClassifyMySQL is the ErrorClassifier for github.com/go-sql-driver/mysql,
+// which reports the MySQL error number. Names are taken from the message.
+func ClassifyMySQL(err error) (ErrorKind, string) {
+	number, ok := errorInt(err, "Number")
+	if !ok {
+		return UnknownError, ""
+	}
+	message, ok := errorString(err, "Message")
+	if !ok {
+		return UnknownError, ""
+	}
+	// SQL Server errors have a number and message too, but also a
+	// severity class
+	if _, ok := errorInt(err, "Class"); ok {
+		return UnknownError, ""
+	}
+	match := func(re *regexp.Regexp) string {
+		if m := re.FindStringSubmatch(message); m != nil {
+			return m[1]
+		}
+		return ""
+	}
+
+	switch number {
+	case 1062, 1586:
+		return UniqueViolation, match(mysqlDuplicateKey)
+	case 1216, 1217, 1451, 1452:
+		return ForeignKeyViolation, match(mysqlConstraint)
+	case 1048:
+		return NotNullViolation, match(mysqlColumn)
+	case 3819:
+		return CheckViolation, match(mysqlCheck)
+	case 1213:
+		return Deadlock, ""
+	}
+	return UnknownError, ""
+}
+
+var (
+	sqlserverConstraint = regexp.MustCompile(`constraint "([^"]*)"`)
+	sqlserverKey        = regexp.MustCompile(`constraint '([^']*)'|unique index '([^']*)'`)
+	sqlserverColumn     = regexp.MustCompile(`column '([^']*)'`)
+)
+
+// ClassifySQLServer is the ErrorClassifier for github.com/microsoft/go-mssqldb,
+// which reports the SQL Server error number. Names are taken from the message.
+func ClassifySQLServer(err error) (ErrorKind, string) {
+	number, ok := errorInt(err, "Number")
+	if !ok {
+		return UnknownError, ""
+	}
+	message, ok := errorString(err, "Message")
+	if !ok {
+		return UnknownError, ""
+	}
+	// the severity class tells these apart from MySQL errors
+	if _, ok := errorInt(err, "Class"); !ok {
+		return UnknownError, ""
+	}
+	match := func(re *regexp.Regexp) string {
+		if m := re.FindStringSubmatch(message); m != nil {
+			for _, s := range m[1:] {
+				if s != "" {
+					return s
+				}
+			}
+		}
+		return ""
+	}
+
+	switch number {
+	case 2601, 2627:
+		return UniqueViolation, match(sqlserverKey)
+	case 547:
+		if strings.Contains(message, "CHECK constraint") {
+			return CheckViolation, match(sqlserverConstraint)
+		}
+		return ForeignKeyViolation, match(sqlserverConstraint)
+	case 515:
+		return NotNullViolation, match(sqlserverColumn)
+	case 1205:
+		return Deadlock, ""
+	case 3960:
+		return SerializationFailure, ""
+	}
+	return UnknownError, ""
+}
+
+// postgresErrors are the error types of the PostgreSQL drivers.
+var postgresErrors = []string{
+	"github.com/lib/pq.Error",
+	"github.com/jackc/pgconn.PgError",
+	"github.com/jackc/pgx/v5/pgconn.PgError",
+}
+
+// ClassifyPostgreSQL is the ErrorClassifier for github.com/lib/pq and
+// github.com/jackc/pgx, which report the SQLSTATE code of the error along
+// with the constraint and column names.
+func ClassifyPostgreSQL(err error) (ErrorKind, string) {
+	if !errorType(err, postgresErrors) {
+		return UnknownError, ""
+	}
+	code, ok := errorString(err, "Code")
+	if !ok || len(code) != 5 {
+		return UnknownError, ""
+	}
+	field := func(names ...string) string {
+		for _, name := range names {
+			if s, ok := errorString(err, name); ok {
+				return s
+			}
+		}
+		return ""
+	}
+
+	switch code {
+	case "23505":
+		return UniqueViolation, field("Constraint", "ConstraintName")
+	case "23503":
+		return ForeignKeyViolation, field("Constraint", "ConstraintName")
+	case "23502":
+		return NotNullViolation, field("Column", "ColumnName")
+	case "23514":
+		return CheckViolation, field("Constraint", "ConstraintName")
+	case "40P01":
+		return Deadlock, ""
+	case "40001":
+		return SerializationFailure, ""
+	}
+	return UnknownError, ""
+}
+
+// oracleName matches the constraint or column in an Oracle error message,
+// e.g. (APP.TAG_NAME_UK) or ("APP"."STOCK"."QTY").
+var oracleName = regexp.MustCompile(`\(([^)]*)\)`)
+
+// oracleErrors are the error types of the Oracle drivers.
+var oracleErrors = []string{
+	"github.com/sijms/go-ora/network.OracleError",
+	"github.com/sijms/go-ora/v2/network.OracleError",
+	"github.com/godror/godror.OraErr",
+}
+
+// ClassifyOracle is the ErrorClassifier for github.com/sijms/go-ora, which
+// reports the ORA- error number, and for github.com/godror/godror, whose
+// errors have a Code method that does. Names are taken from the message.
+func ClassifyOracle(err error) (ErrorKind, string) {
+	if !errorType(err, oracleErrors) {
+		return UnknownError, ""
+	}
+	number, ok := errorInt(err, "ErrCode")
+	if coder, isCoder := err.(interface{ Code() int }); isCoder && !ok {
+		number, ok = int64(coder.Code()), true
+	}
+	if !ok {
+		return UnknownError, ""
+	}
+	name := ""
+	if m := oracleName.FindStringSubmatch(err.Error()); m != nil {
+		name = m[1]
+	}
+
+	switch number {
+	case 1:
+		return UniqueViolation, name
+	case 2291, 2292:
+		return ForeignKeyViolation, name
+	case 1400:
+		return NotNullViolation, name
+	case 2290:
+		return CheckViolation, name
+	case 60:
+		return Deadlock, ""
+	case 8177:
+		return SerializationFailure, ""
+	}
+	return UnknownError, ""
+}
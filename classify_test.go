@@ -0,0 +1,223 @@
+package meddler
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+type Stock struct {
+	ID    int64  `meddler:"id,pk"`
+	TagID *int64 `meddler:"tag_id"`
+	Qty   *int   `meddler:"qty"`
+}
+
+func TestClassifySQLite(t *testing.T) {
+	once.Do(setup)
+	if _, err := db.Exec("PRAGMA foreign_keys = ON"); err != nil {
+		t.Fatalf("PRAGMA error: %v", err)
+	}
+	defer db.Exec("PRAGMA foreign_keys = OFF")
+
+	if err := Insert(db, "tag", &Tag{Name: "go"}); err != nil {
+		t.Fatalf("Insert error: %v", err)
+	}
+	err := Insert(db, "tag", &Tag{Name: "go"})
+	if name, ok := SQLite.IsUniqueViolation(err); !ok || name != "tag.name" {
+		t.Errorf("IsUniqueViolation: expected tag.name, got %q, %v for %v", name, ok, err)
+	}
+	if _, ok := SQLite.IsNotNullViolation(err); ok {
+		t.Errorf("IsNotNullViolation: expected false for a unique violation")
+	}
+
+	missing, qty, negative := int64(999), 1, -1
+	err = Insert(db, "stock", &Stock{TagID: &missing, Qty: &qty})
+	if _, ok := SQLite.IsForeignKeyViolation(err); !ok {
+		t.Errorf("IsForeignKeyViolation: expected true for %v", err)
+	}
+	err = Insert(db, "stock", &Stock{})
+	if name, ok := SQLite.IsNotNullViolation(err); !ok || name != "stock.qty" {
+		t.Errorf("IsNotNullViolation: expected stock.qty, got %q, %v for %v", name, ok, err)
+	}
+	err = Insert(db, "stock", &Stock{Qty: &negative})
+	if name, ok := SQLite.IsCheckViolation(err); !ok || name != "qty_positive" {
+		t.Errorf("IsCheckViolation: expected qty_positive, got %q, %v for %v", name, ok, err)
+	}
+
+	// a nil classifier tries every driver
+	d := *SQLite
+	d.Classifier = nil
+	if kind, _ := d.ClassifyError(err); kind != CheckViolation {
+		t.Errorf("ClassifyError without a classifier: expected CheckViolation, got %v", kind)
+	}
+	if kind, _ := d.ClassifyError(errors.New("some other error")); kind != UnknownError {
+		t.Errorf("ClassifyError of a plain error: expected UnknownError, got %v", kind)
+	}
+
+	// so do the package-level functions, whatever the Default classifier
+	if Default.Classifier == nil {
+		t.Fatalf("Default is expected to have a classifier")
+	}
+	if kind, _ := ClassifyError(err); kind != CheckViolation {
+		t.Errorf("package ClassifyError: expected CheckViolation, got %v", kind)
+	}
+	if _, ok := IsCheckViolation(err); !ok {
+		t.Errorf("package IsCheckViolation: expected true for %v", err)
+	}
+	db.Exec("delete from stock")
+	db.Exec("delete from tag")
+}
+
+func init() {
+	// the fakes stand in for the driver types the classifiers look for
+	postgresErrors = append(postgresErrors, "github.com/russross/meddler.fakePQError", "github.com/russross/meddler.fakePgError")
+	oracleErrors = append(oracleErrors, "github.com/russross/meddler.fakeOracleError", "github.com/russross/meddler.fakeOraErr")
+}
+
+// fakeMySQLError has the same shape as mysql.MySQLError
+type fakeMySQLError struct {
+	Number   uint16
+	SQLState [5]byte
+	Message  string
+}
+
+func (err *fakeMySQLError) Error() string {
+	return fmt.Sprintf("Error %d: %s", err.Number, err.Message)
+}
+
+// fakePQError has the same shape as pq.Error
+type fakePQError struct {
+	Code       string
+	Message    string
+	Column     string
+	Constraint string
+}
+
+func (err *fakePQError) Error() string {
+	return "pq: " + err.Message
+}
+
+// fakePgError has the same shape as pgconn.PgError
+type fakePgError struct {
+	Code           string
+	Message        string
+	ColumnName     string
+	ConstraintName string
+}
+
+func (err fakePgError) Error() string {
+	return err.Message
+}
+
+// fakeMSSQLError has the same shape as mssql.Error
+type fakeMSSQLError struct {
+	Number  int32
+	State   uint8
+	Class   uint8
+	Message string
+}
+
+func (err fakeMSSQLError) Error() string {
+	return "mssql: " + err.Message
+}
+
+// fakeSQLiteError has the same shape as sqlite3.Error
+type fakeSQLiteError struct {
+	Code         int
+	ExtendedCode int
+}
+
+func (err *fakeSQLiteError) Error() string {
+	return fmt.Sprintf("sqlite error %d", err.ExtendedCode)
+}
+
+// fakeOracleError has the same shape as network.OracleError in go-ora
+type fakeOracleError struct {
+	ErrCode int
+	ErrMsg  string
+}
+
+func (err *fakeOracleError) Error() string {
+	return err.ErrMsg
+}
+
+// fakeOraErr has the same methods as godror.OraErr
+type fakeOraErr struct {
+	code    int
+	message string
+}
+
+func (err *fakeOraErr) Code() int     { return err.code }
+func (err *fakeOraErr) Error() string { return err.message }
+
+// codeError has a code like a driver error, but does not come from one
+type codeError struct {
+	Code string
+}
+
+func (err codeError) Error() string { return "code " + err.Code }
+
+type numberError int
+
+func (err numberError) Code() int     { return int(err) }
+func (err numberError) Error() string { return fmt.Sprintf("number %d", int(err)) }
+
+func TestClassifyDrivers(t *testing.T) {
+	tests := []struct {
+		d    *Database
+		err  error
+		kind ErrorKind
+		name string
+	}{
+		{MySQL, &fakeMySQLError{Number: 1062, Message: "Duplicate entry 'go' for key 'tag.name'"}, UniqueViolation, "tag.name"},
+		{MySQL, &fakeMySQLError{Number: 1452, Message: "Cannot add or update a child row: a foreign key constraint fails (`test`.`stock`, CONSTRAINT `stock_ibfk_1` FOREIGN KEY (`tag_id`) REFERENCES `tag` (`id`))"}, ForeignKeyViolation, "stock_ibfk_1"},
+		{MySQL, &fakeMySQLError{Number: 1048, Message: "Column 'qty' cannot be null"}, NotNullViolation, "qty"},
+		{MySQL, &fakeMySQLError{Number: 3819, Message: "Check constraint 'qty_positive' is violated."}, CheckViolation, "qty_positive"},
+		{MySQL, &fakeMySQLError{Number: 1213, Message: "Deadlock found when trying to get lock"}, Deadlock, ""},
+		{MySQL, &fakeMySQLError{Number: 1146, Message: "Table 'test.nosuchtable' doesn't exist"}, UnknownError, ""},
+		{PostgreSQL, &fakePQError{Code: "23505", Constraint: "tag_name_key"}, UniqueViolation, "tag_name_key"},
+		{PostgreSQL, &fakePQError{Code: "23502", Column: "qty"}, NotNullViolation, "qty"},
+		{PostgreSQL, fakePgError{Code: "23503", ConstraintName: "stock_tag_id_fkey"}, ForeignKeyViolation, "stock_tag_id_fkey"},
+		{PostgreSQL, fakePgError{Code: "23514", ConstraintName: "qty_positive"}, CheckViolation, "qty_positive"},
+		{PostgreSQL, fakePgError{Code: "40P01"}, Deadlock, ""},
+		{PostgreSQL, fakePgError{Code: "40001"}, SerializationFailure, ""},
+		{PostgreSQL, &fakeMySQLError{Number: 1062, Message: "Duplicate entry"}, UnknownError, ""},
+		{SQLServer, fakeMSSQLError{Number: 2627, Message: "Violation of UNIQUE KEY constraint 'UQ_tag_name'. Cannot insert duplicate key in object 'dbo.tag'."}, UniqueViolation, "UQ_tag_name"},
+		{SQLServer, fakeMSSQLError{Number: 2601, Message: "Cannot insert duplicate key row in object 'dbo.tag' with unique index 'ix_tag_name'."}, UniqueViolation, "ix_tag_name"},
+		{SQLServer, fakeMSSQLError{Number: 547, Message: `The INSERT statement conflicted with the FOREIGN KEY constraint "FK_stock_tag".`}, ForeignKeyViolation, "FK_stock_tag"},
+		{SQLServer, fakeMSSQLError{Number: 547, Message: `The INSERT statement conflicted with the CHECK constraint "qty_positive".`}, CheckViolation, "qty_positive"},
+		{SQLServer, fakeMSSQLError{Number: 515, Message: "Cannot insert the value NULL into column 'qty', table 'test.dbo.stock'; column does not allow nulls."}, NotNullViolation, "qty"},
+		{SQLServer, fakeMSSQLError{Number: 1205, Message: "Transaction was deadlocked"}, Deadlock, ""},
+		{Oracle, &fakeOracleError{ErrCode: 1, ErrMsg: "ORA-00001: unique constraint (APP.TAG_NAME_UK) violated"}, UniqueViolation, "APP.TAG_NAME_UK"},
+		{Oracle, &fakeOracleError{ErrCode: 2291, ErrMsg: "ORA-02291: integrity constraint (APP.STOCK_TAG_FK) violated - parent key not found"}, ForeignKeyViolation, "APP.STOCK_TAG_FK"},
+		{Oracle, &fakeOracleError{ErrCode: 1400, ErrMsg: `ORA-01400: cannot insert NULL into ("APP"."STOCK"."QTY")`}, NotNullViolation, `"APP"."STOCK"."QTY"`},
+		{Oracle, &fakeOracleError{ErrCode: 2290, ErrMsg: "ORA-02290: check constraint (APP.QTY_POSITIVE) violated"}, CheckViolation, "APP.QTY_POSITIVE"},
+		{Oracle, &fakeOracleError{ErrCode: 8177, ErrMsg: "ORA-08177: can't serialize access for this transaction"}, SerializationFailure, ""},
+		{Oracle, &fakeOraErr{code: 60, message: "ORA-00060: deadlock detected while waiting for resource"}, Deadlock, ""},
+
+		// errors from other sources that happen to look similar
+		{PostgreSQL, codeError{Code: "23505"}, UnknownError, ""},
+		{Oracle, numberError(1), UnknownError, ""},
+
+		// without a classifier, every built-in one is tried
+		{&Database{}, fakeMSSQLError{Number: 1205, Message: "Transaction was deadlocked"}, Deadlock, ""},
+		{&Database{}, &fakeMySQLError{Number: 1213, Message: "Deadlock found when trying to get lock"}, Deadlock, ""},
+		{&Database{}, &fakeSQLiteError{Code: 6, ExtendedCode: 6}, UnknownError, ""},
+		{MySQL, fakeMSSQLError{Number: 1062, Class: 14, Message: "not from MySQL"}, UnknownError, ""},
+	}
+	for i, test := range tests {
+		// errors are found through wrappers
+		err := &QueryError{Op: "meddler.Insert", Err: test.err}
+		kind, name := test.d.ClassifyError(fmt.Errorf("wrapped: %w", err))
+		if kind != test.kind || name != test.name {
+			t.Errorf("test %d: expected %v %q, found %v %q", i, test.kind, test.name, kind, name)
+		}
+	}
+
+	if name, ok := MySQL.IsDeadlock(&fakeMySQLError{Number: 1213}); !ok || name != "" {
+		t.Errorf("IsDeadlock: expected true, got %q, %v", name, ok)
+	}
+	if _, ok := PostgreSQL.IsSerializationFailure(nil); ok {
+		t.Errorf("IsSerializationFailure of nil: expected false")
+	}
+}
@@ -0,0 +1,654 @@
+package meddler
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Dialect describes the SQL syntax of a database. A Database uses its
+// Dialect to build every query it generates. MySQLDialect,
+// PostgreSQLDialect, SQLiteDialect, SQLServerDialect, and OracleDialect
+// are provided, and other databases can be supported by implementing this
+// interface, along with the optional interfaces that follow it.
+type Dialect interface {
+	// QuoteIdentifier quotes a single identifier, escaping any quote
+	// characters in it. A dot is part of the name like any other
+	// character; Database.QuoteTable splits a table name qualified with
+	// its schema and quotes each part with this method.
+	QuoteIdentifier(name string) string
+
+	// BracketQuotes reports whether the database quotes identifiers with
+	// square brackets, so a [ in a hand-written query starts a quoted
+	// name that Rebind, ExpandIn, and BindNamed must skip.
+	BracketQuotes() bool
+
+	// Placeholder returns the placeholder for the nth query argument,
+	// counting from 1.
+	Placeholder(n int) string
+
+	// PlaceholderPrefix returns the text before the number in the
+	// placeholders returned by Placeholder, such as "$" for $1, or "" if
+	// every placeholder is ?. Rebind and ExpandIn use it to recognize
+	// numbered placeholders in hand-written queries.
+	PlaceholderPrefix() string
+
+	// InsertReturning returns the clauses that make an INSERT statement
+	// produce the value of column, which is already quoted, as a result
+	// row: output goes between the column list and VALUES, and returning
+	// goes at the end. If ok is false, the key allocated by the database
+	// is read with sql.Result.LastInsertId instead.
+	InsertReturning(column string) (output, returning string, ok bool)
+
+	// UpsertSyntax returns the clause used by Upsert to update a row
+	// that already exists.
+	UpsertSyntax() UpsertSyntax
+
+	// LimitOffset returns the clause, with a leading space, that limits
+	// the rows returned by a SELECT statement. A negative limit means no
+	// limit, and an offset of zero means no rows are skipped. It returns
+	// "" if there is nothing to add.
+	LimitOffset(limit, offset int) string
+
+	// LockClause returns the clause, with a leading space, that is added
+	// to a SELECT statement to lock the rows it returns, or "" if the
+	// database has no row locks.
+	LockClause(mode LockMode) string
+}
+
+// ReturningIntoDialect is implemented by dialects that read a new key back
+// through an output parameter, as Oracle does with RETURNING ... INTO.
+// Insert uses it when InsertReturning is not supported, and InsertAll
+// then inserts one row per statement.
+type ReturningIntoDialect interface {
+	// InsertReturningInto returns the clause, with a leading space, that
+	// stores the value of column, which is already quoted, in output
+	// parameter n of an INSERT statement.
+	InsertReturningInto(column string, n int) string
+}
+
+// SequenceDialect is implemented by dialects that support the sequence
+// tag option, e.g. `meddler:"id,pk,sequence=person_seq"`.
+type SequenceDialect interface {
+	// NextValue returns a query that produces the next value of the
+	// named sequence as a single row.
+	NextValue(sequence string) string
+}
+
+// KeyBlockDialect is implemented by dialects that can allocate the keys
+// of several new rows before they are inserted. InsertAll uses it to
+// insert rows that need a key together, and still know which key belongs
+// to which row.
+type KeyBlockDialect interface {
+	// NextKeys returns a query that produces n new values for column of
+	// table as n rows of a single column, or rows of NULL if the column
+	// does not take its values from a sequence. table and column are not
+	// quoted. override is the clause, with a leading space, that goes
+	// between the column list and VALUES so the database accepts the
+	// keys, or "" if none is needed.
+	NextKeys(table, column string, n int) (query, override string)
+}
+
+// OrderedLimitDialect is implemented by dialects whose LimitOffset clause
+// is only accepted after an ORDER BY clause.
+type OrderedLimitDialect interface {
+	// LimitOrderBy returns the expression to order by when a query with
+	// a LimitOffset clause does not order its rows.
+	LimitOrderBy() string
+}
+
+// IdentifierFolder is implemented by dialects of databases that fold
+// unquoted identifiers to one case, and so may report result columns in
+// a different case from the names in struct tags.
+type IdentifierFolder interface {
+	// FoldIdentifier returns name as the database would store it.
+	FoldIdentifier(name string) string
+}
+
+// LockMode selects the kind of row lock taken by a SELECT statement.
+type LockMode int
+
+const (
+	// LockForUpdate takes an exclusive lock, as for a row that is
+	// about to be updated.
+	LockForUpdate LockMode = iota
+
+	// LockForShare takes a shared lock, which stops other transactions
+	// changing the row but still lets them read it.
+	LockForShare
+)
+
+// MySQLDialect is the Dialect of MySQL and MariaDB.
+type MySQLDialect struct{}
+
+// QuoteIdentifier quotes name with backticks.
+func (MySQLDialect) QuoteIdentifier(name string) string {
+	return quoteIdentifier(name, "`", "`")
+}
+
+// BracketQuotes returns false.
+func (MySQLDialect) BracketQuotes() bool {
+	return false
+}
+
+// Placeholder returns "?" for every argument.
+func (MySQLDialect) Placeholder(n int) string {
+	return "?"
+}
+
+// PlaceholderPrefix returns "", since placeholders are not numbered.
+func (MySQLDialect) PlaceholderPrefix() string {
+	return ""
+}
+
+// InsertReturning is not supported, so LastInsertId is used.
+func (MySQLDialect) InsertReturning(column string) (string, string, bool) {
+	return "", "", false
+}
+
+// UpsertSyntax returns UpsertOnDuplicateKey.
+func (MySQLDialect) UpsertSyntax() UpsertSyntax {
+	return UpsertOnDuplicateKey
+}
+
+// LimitOffset returns LIMIT n OFFSET m. MySQL cannot skip rows without a
+// limit, so an offset alone comes with the largest possible limit.
+func (MySQLDialect) LimitOffset(limit, offset int) string {
+	if limit < 0 && offset > 0 {
+		return fmt.Sprintf(" LIMIT 18446744073709551615 OFFSET %d", offset)
+	}
+	return limitOffset(limit, offset)
+}
+
+// LockClause returns FOR UPDATE or LOCK IN SHARE MODE.
+func (MySQLDialect) LockClause(mode LockMode) string {
+	if mode == LockForShare {
+		return " LOCK IN SHARE MODE"
+	}
+	return " FOR UPDATE"
+}
+
+// PostgreSQLDialect is the Dialect of PostgreSQL.
+type PostgreSQLDialect struct{}
+
+// QuoteIdentifier quotes name with double quotes.
+func (PostgreSQLDialect) QuoteIdentifier(name string) string {
+	return quoteIdentifier(name, `"`, `"`)
+}
+
+// BracketQuotes returns false.
+func (PostgreSQLDialect) BracketQuotes() bool {
+	return false
+}
+
+// Placeholder returns $1, $2, and so on.
+func (PostgreSQLDialect) Placeholder(n int) string {
+	return "$" + strconv.Itoa(n)
+}
+
+// PlaceholderPrefix returns "$".
+func (PostgreSQLDialect) PlaceholderPrefix() string {
+	return "$"
+}
+
+// InsertReturning adds a RETURNING clause.
+func (PostgreSQLDialect) InsertReturning(column string) (string, string, bool) {
+	return "", " RETURNING " + column, true
+}
+
+// NextKeys draws n values from the sequence that pg_get_serial_sequence
+// finds for the column, which covers serial and identity columns. The
+// keys are inserted with OVERRIDING SYSTEM VALUE, so columns declared
+// GENERATED ALWAYS AS IDENTITY accept them too.
+func (d PostgreSQLDialect) NextKeys(table, column string, n int) (string, string) {
+	sequence := fmt.Sprintf("pg_get_serial_sequence(%s,%s)", quoteString(quoteQualified(table, d.QuoteIdentifier)), quoteString(column))
+	return fmt.Sprintf("SELECT nextval(%s) FROM generate_series(1,%d)", sequence, n), " OVERRIDING SYSTEM VALUE"
+}
+
+// UpsertSyntax returns UpsertOnConflict.
+func (PostgreSQLDialect) UpsertSyntax() UpsertSyntax {
+	return UpsertOnConflict
+}
+
+// LimitOffset returns LIMIT n OFFSET m.
+func (PostgreSQLDialect) LimitOffset(limit, offset int) string {
+	return limitOffset(limit, offset)
+}
+
+// LockClause returns FOR UPDATE or FOR SHARE.
+func (PostgreSQLDialect) LockClause(mode LockMode) string {
+	if mode == LockForShare {
+		return " FOR SHARE"
+	}
+	return " FOR UPDATE"
+}
+
+// SQLiteDialect is the Dialect of SQLite.
+type SQLiteDialect struct{}
+
+// QuoteIdentifier quotes name with double quotes.
+func (SQLiteDialect) QuoteIdentifier(name string) string {
+	return quoteIdentifier(name, `"`, `"`)
+}
+
+// BracketQuotes returns false. SQLite accepts names in square brackets
+// for compatibility, but meddler never writes them.
+func (SQLiteDialect) BracketQuotes() bool {
+	return false
+}
+
+// Placeholder returns "?" for every argument.
+func (SQLiteDialect) Placeholder(n int) string {
+	return "?"
+}
+
+// PlaceholderPrefix returns "", since placeholders are not numbered.
+func (SQLiteDialect) PlaceholderPrefix() string {
+	return ""
+}
+
+// InsertReturning is not used, since RETURNING needs SQLite 3.35 or
+// later, so LastInsertId is used instead.
+func (SQLiteDialect) InsertReturning(column string) (string, string, bool) {
+	return "", "", false
+}
+
+// UpsertSyntax returns UpsertOnConflict.
+func (SQLiteDialect) UpsertSyntax() UpsertSyntax {
+	return UpsertOnConflict
+}
+
+// LimitOffset returns LIMIT n OFFSET m, using LIMIT -1 for an offset
+// without a limit.
+func (SQLiteDialect) LimitOffset(limit, offset int) string {
+	if limit < 0 && offset > 0 {
+		return fmt.Sprintf(" LIMIT -1 OFFSET %d", offset)
+	}
+	return limitOffset(limit, offset)
+}
+
+// LockClause returns "", since SQLite locks the whole database.
+func (SQLiteDialect) LockClause(mode LockMode) string {
+	return ""
+}
+
+// SQLServerDialect is the Dialect of Microsoft SQL Server.
+type SQLServerDialect struct{}
+
+// QuoteIdentifier quotes name with square brackets.
+func (SQLServerDialect) QuoteIdentifier(name string) string {
+	return quoteIdentifier(name, "[", "]")
+}
+
+// BracketQuotes returns true.
+func (SQLServerDialect) BracketQuotes() bool {
+	return true
+}
+
+// Placeholder returns @p1, @p2, and so on.
+func (SQLServerDialect) Placeholder(n int) string {
+	return "@p" + strconv.Itoa(n)
+}
+
+// PlaceholderPrefix returns "@p".
+func (SQLServerDialect) PlaceholderPrefix() string {
+	return "@p"
+}
+
+// InsertReturning adds an OUTPUT clause, since SQL Server drivers
+// do not support LastInsertId. OUTPUT does not promise to return rows in
+// the order they were inserted, so InsertAll only uses it for one row at a
+// time.
+func (SQLServerDialect) InsertReturning(column string) (string, string, bool) {
+	return " OUTPUT INSERTED." + column, "", true
+}
+
+// UpsertSyntax returns UpsertNone, since SQL Server only has MERGE.
+func (SQLServerDialect) UpsertSyntax() UpsertSyntax {
+	return UpsertNone
+}
+
+// LimitOffset returns OFFSET m ROWS FETCH NEXT n ROWS ONLY, which
+// SQL Server only accepts after an ORDER BY clause.
+func (SQLServerDialect) LimitOffset(limit, offset int) string {
+	return offsetFetch(limit, offset)
+}
+
+// LimitOrderBy returns (SELECT NULL), which satisfies the ORDER BY that
+// LimitOffset needs without choosing an order.
+func (SQLServerDialect) LimitOrderBy() string {
+	return "(SELECT NULL)"
+}
+
+// LockClause returns "", since SQL Server takes row locks with table
+// hints such as WITH (UPDLOCK) after the table name instead.
+func (SQLServerDialect) LockClause(mode LockMode) string {
+	return ""
+}
+
+// OracleDialect is the Dialect of Oracle Database 12c and later. Names
+// in lower case are folded to upper case before they are quoted, to match
+// tables and columns that were created without quotes. Names with any
+// upper case letters are quoted as they are, so tables and columns that
+// were created with quotes in mixed case can still be used.
+type OracleDialect struct{}
+
+// QuoteIdentifier folds name as FoldIdentifier does and quotes it with
+// double quotes.
+func (d OracleDialect) QuoteIdentifier(name string) string {
+	return quoteIdentifier(d.FoldIdentifier(name), `"`, `"`)
+}
+
+// BracketQuotes returns false.
+func (OracleDialect) BracketQuotes() bool {
+	return false
+}
+
+// Placeholder returns :1, :2, and so on.
+func (OracleDialect) Placeholder(n int) string {
+	return ":" + strconv.Itoa(n)
+}
+
+// PlaceholderPrefix returns ":".
+func (OracleDialect) PlaceholderPrefix() string {
+	return ":"
+}
+
+// InsertReturning is not supported, since Oracle only returns values
+// through output parameters; see InsertReturningInto.
+func (OracleDialect) InsertReturning(column string) (string, string, bool) {
+	return "", "", false
+}
+
+// InsertReturningInto adds a RETURNING ... INTO clause.
+func (OracleDialect) InsertReturningInto(column string, n int) string {
+	return fmt.Sprintf(" RETURNING %s INTO :%d", column, n)
+}
+
+// NextValue selects NEXTVAL of the sequence from DUAL.
+func (d OracleDialect) NextValue(sequence string) string {
+	return "SELECT " + quoteQualified(sequence, d.QuoteIdentifier) + ".NEXTVAL FROM DUAL"
+}
+
+// FoldIdentifier folds name to upper case if it has no upper case
+// letters, and otherwise returns it unchanged.
+func (OracleDialect) FoldIdentifier(name string) string {
+	if strings.ToLower(name) != name {
+		return name
+	}
+	return strings.ToUpper(name)
+}
+
+// UpsertSyntax returns UpsertNone, since Oracle only has MERGE.
+func (OracleDialect) UpsertSyntax() UpsertSyntax {
+	return UpsertNone
+}
+
+// LimitOffset returns OFFSET m ROWS FETCH NEXT n ROWS ONLY.
+func (OracleDialect) LimitOffset(limit, offset int) string {
+	return offsetFetch(limit, offset)
+}
+
+// LockClause returns FOR UPDATE, or "" for LockForShare, which Oracle
+// does not support.
+func (OracleDialect) LockClause(mode LockMode) string {
+	if mode == LockForShare {
+		return ""
+	}
+	return " FOR UPDATE"
+}
+
+// quoteIdentifier quotes name between open and close, doubling any close
+// quote characters inside it.
+func quoteIdentifier(name, open, close string) string {
+	if close == "" {
+		return name
+	}
+	return open + strings.ReplaceAll(name, close, close+close) + close
+}
+
+// quoteString quotes s as an SQL string literal.
+func quoteString(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// quoteQualified quotes each dotted part of name, such as a table
+// qualified with its schema, using quote.
+func quoteQualified(name string, quote func(string) string) string {
+	parts := strings.Split(name, ".")
+	for i, part := range parts {
+		parts[i] = quote(part)
+	}
+	return strings.Join(parts, ".")
+}
+
+// validIdentifier reports whether each dotted part of name is a valid
+// name, as checked by validName.
+func validIdentifier(name string) bool {
+	for _, part := range strings.Split(name, ".") {
+		if !validName(part) {
+			return false
+		}
+	}
+	return true
+}
+
+// validName reports whether name is made of ASCII letters, digits, and
+// underscores, and does not start with a digit.
+func validName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, r := range name {
+		switch {
+		case r == '_', 'a' <= r && r <= 'z', 'A' <= r && r <= 'Z':
+		case '0' <= r && r <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// limitOffset forms the standard LIMIT and OFFSET clauses.
+func limitOffset(limit, offset int) string {
+	var clause string
+	if limit >= 0 {
+		clause += fmt.Sprintf(" LIMIT %d", limit)
+	}
+	if offset > 0 {
+		clause += fmt.Sprintf(" OFFSET %d", offset)
+	}
+	return clause
+}
+
+// offsetFetch forms the SQL:2008 OFFSET and FETCH NEXT clauses.
+func offsetFetch(limit, offset int) string {
+	if limit < 0 && offset <= 0 {
+		return ""
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	clause := fmt.Sprintf(" OFFSET %d ROWS", offset)
+	if limit >= 0 {
+		clause += fmt.Sprintf(" FETCH NEXT %d ROWS ONLY", limit)
+	}
+	return clause
+}
+
+// fieldsDialect is the Dialect of a Database with no Dialect set,
+// built from the Quote, Placeholder, UseReturningToGetID, UpsertSyntax,
+// and RowLocks fields.
+type fieldsDialect struct {
+	d *Database
+}
+
+func (f fieldsDialect) QuoteIdentifier(name string) string {
+	return quoteIdentifier(name, f.d.Quote, f.d.Quote)
+}
+
+func (f fieldsDialect) BracketQuotes() bool {
+	return false
+}
+
+func (f fieldsDialect) Placeholder(n int) string {
+	return strings.Replace(f.d.Placeholder, "1", strconv.FormatInt(int64(n), 10), 1)
+}
+
+func (f fieldsDialect) PlaceholderPrefix() string {
+	if i := strings.Index(f.d.Placeholder, "1"); i >= 0 {
+		return f.d.Placeholder[:i]
+	}
+	return ""
+}
+
+func (f fieldsDialect) InsertReturning(column string) (string, string, bool) {
+	if !f.d.UseReturningToGetID {
+		return "", "", false
+	}
+	return "", " RETURNING " + column, true
+}
+
+func (f fieldsDialect) UpsertSyntax() UpsertSyntax {
+	return f.d.UpsertSyntax
+}
+
+func (f fieldsDialect) LimitOffset(limit, offset int) string {
+	return limitOffset(limit, offset)
+}
+
+func (f fieldsDialect) LockClause(mode LockMode) string {
+	if !f.d.RowLocks {
+		return ""
+	}
+	if mode == LockForShare {
+		return " FOR SHARE"
+	}
+	return " FOR UPDATE"
+}
+
+// dialect returns the Dialect of the database.
+func (d *Database) dialect() Dialect {
+	if d.Dialect != nil {
+		return d.Dialect
+	}
+	return fieldsDialect{d: d}
+}
+
+// CheckIdentifier returns an error that wraps ErrInvalidIdentifier if
+// StrictIdentifiers is set and name is not a valid table name, so code
+// that builds its own queries can apply the same check as meddler. Each
+// dotted part must be made of letters, digits, and underscores, and must
+// not start with a digit.
+func (d *Database) CheckIdentifier(name string) error {
+	if d.StrictIdentifiers && !validIdentifier(name) {
+		return fmt.Errorf("meddler: %q: %w", name, ErrInvalidIdentifier)
+	}
+	return nil
+}
+
+// CheckIdentifier using the Default Database type
+func CheckIdentifier(name string) error {
+	return Default.CheckIdentifier(name)
+}
+
+// checkIdentifiers makes sure the table and the columns of src are valid
+// identifiers when StrictIdentifiers is set.
+func (d *Database) checkIdentifiers(op, table string, src interface{}) error {
+	if !d.StrictIdentifiers {
+		return nil
+	}
+	if !validIdentifier(table) {
+		return fmt.Errorf("%s: table %q: %w", op, table, ErrInvalidIdentifier)
+	}
+	data, err := getFields(reflect.TypeOf(src))
+	if err != nil {
+		return err
+	}
+	for _, name := range data.columns {
+		if !validName(name) {
+			return fmt.Errorf("%s: column %q: %w", op, name, ErrInvalidIdentifier)
+		}
+	}
+	return nil
+}
+
+// returnsKeys reports whether the database can hand back a key it
+// allocates without relying on LastInsertId.
+func (d *Database) returnsKeys() bool {
+	if _, _, ok := d.dialect().InsertReturning(""); ok {
+		return true
+	}
+	_, ok := d.dialect().(ReturningIntoDialect)
+	return ok
+}
+
+// resultColumns matches the result columns of a query to the columns of
+// a struct when the database folds the case of unquoted names.
+func (d *Database) resultColumns(data *structData, columns []string) []string {
+	folder, ok := d.dialect().(IdentifierFolder)
+	if !ok {
+		return columns
+	}
+	folded := make(map[string]string)
+	for _, name := range data.columns {
+		folded[folder.FoldIdentifier(name)] = name
+	}
+	var result []string
+	for _, name := range columns {
+		if _, present := data.fields[name]; !present {
+			if match, present := folded[name]; present {
+				name = match
+			}
+		}
+		result = append(result, name)
+	}
+	return result
+}
+
+// QuoteIdentifier quotes a single table or column name for the database.
+// A dot in name is quoted as part of the name; use QuoteTable for a table
+// qualified with its schema.
+func (d *Database) QuoteIdentifier(name string) string {
+	return d.dialect().QuoteIdentifier(name)
+}
+
+// QuoteTable quotes a table name for the database. The parts of a dotted
+// name, such as a table qualified with its schema, are quoted separately.
+func (d *Database) QuoteTable(name string) string {
+	return quoteQualified(name, d.dialect().QuoteIdentifier)
+}
+
+// LimitOffset returns the clause that limits the rows returned by a
+// SELECT statement; see Dialect.LimitOffset.
+func (d *Database) LimitOffset(limit, offset int) string {
+	return d.dialect().LimitOffset(limit, offset)
+}
+
+// LockClause returns the clause that locks the rows returned by a
+// SELECT statement; see Dialect.LockClause.
+func (d *Database) LockClause(mode LockMode) string {
+	return d.dialect().LockClause(mode)
+}
+
+// QuoteIdentifier using the Default Database type
+func QuoteIdentifier(name string) string {
+	return Default.QuoteIdentifier(name)
+}
+
+// QuoteTable using the Default Database type
+func QuoteTable(name string) string {
+	return Default.QuoteTable(name)
+}
+
+// LimitOffset using the Default Database type
+func LimitOffset(limit, offset int) string {
+	return Default.LimitOffset(limit, offset)
+}
+
+// LockClause using the Default Database type
+func LockClause(mode LockMode) string {
+	return Default.LockClause(mode)
+}
@@ -0,0 +1,349 @@
+package meddler
+
+import (
+	"database/sql/driver"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDialects(t *testing.T) {
+	tests := []struct {
+		d                   *Database
+		quoted, placeholder string
+		returning           string
+		limit, offset, both string
+		forUpdate, forShare string
+		upsert              UpsertSyntax
+	}{
+		{MySQL, "`name`", "?", "", " LIMIT 10", " LIMIT 18446744073709551615 OFFSET 20", " LIMIT 10 OFFSET 20", " FOR UPDATE", " LOCK IN SHARE MODE", UpsertOnDuplicateKey},
+		{PostgreSQL, `"name"`, "$3", ` RETURNING "id"`, " LIMIT 10", " OFFSET 20", " LIMIT 10 OFFSET 20", " FOR UPDATE", " FOR SHARE", UpsertOnConflict},
+		{SQLite, `"name"`, "?", "", " LIMIT 10", " LIMIT -1 OFFSET 20", " LIMIT 10 OFFSET 20", "", "", UpsertOnConflict},
+
+		// without a Dialect, the older fields are used
+		{&Database{Quote: "'", Placeholder: ":1", UseReturningToGetID: true, UpsertSyntax: UpsertOnConflict, RowLocks: true}, "'name'", ":3", ` RETURNING 'id'`, " LIMIT 10", " OFFSET 20", " LIMIT 10 OFFSET 20", " FOR UPDATE", " FOR SHARE", UpsertOnConflict},
+		{&Database{Quote: `"`, Placeholder: "?"}, `"name"`, "?", "", " LIMIT 10", " OFFSET 20", " LIMIT 10 OFFSET 20", "", "", UpsertNone},
+	}
+	for i, test := range tests {
+		dialect := test.d.dialect()
+		if s := test.d.QuoteIdentifier("name"); s != test.quoted {
+			t.Errorf("%d: QuoteIdentifier: expected %s, found %s", i, test.quoted, s)
+		}
+		if s := dialect.Placeholder(3); s != test.placeholder {
+			t.Errorf("%d: Placeholder: expected %s, found %s", i, test.placeholder, s)
+		}
+		if _, s, _ := dialect.InsertReturning(test.d.quoted("id")); s != test.returning {
+			t.Errorf("%d: InsertReturning: expected %q, found %q", i, test.returning, s)
+		}
+		if s := test.d.LimitOffset(10, 0); s != test.limit {
+			t.Errorf("%d: LimitOffset(10, 0): expected %q, found %q", i, test.limit, s)
+		}
+		if s := test.d.LimitOffset(-1, 20); s != test.offset {
+			t.Errorf("%d: LimitOffset(-1, 20): expected %q, found %q", i, test.offset, s)
+		}
+		if s := test.d.LimitOffset(10, 20); s != test.both {
+			t.Errorf("%d: LimitOffset(10, 20): expected %q, found %q", i, test.both, s)
+		}
+		if s := test.d.LimitOffset(-1, 0); s != "" {
+			t.Errorf("%d: LimitOffset(-1, 0): expected nothing, found %q", i, s)
+		}
+		if s := test.d.LockClause(LockForUpdate); s != test.forUpdate {
+			t.Errorf("%d: LockClause(LockForUpdate): expected %q, found %q", i, test.forUpdate, s)
+		}
+		if s := test.d.LockClause(LockForShare); s != test.forShare {
+			t.Errorf("%d: LockClause(LockForShare): expected %q, found %q", i, test.forShare, s)
+		}
+		if s := dialect.UpsertSyntax(); s != test.upsert {
+			t.Errorf("%d: UpsertSyntax: expected %d, found %d", i, test.upsert, s)
+		}
+	}
+}
+
+// returningSQLite uses RETURNING, which SQLite supports from 3.35
+type returningSQLite struct {
+	SQLiteDialect
+}
+
+func (returningSQLite) InsertReturning(column string) (string, string, bool) {
+	return "", " RETURNING " + column, true
+}
+
+func TestCustomDialect(t *testing.T) {
+	once.Do(setup)
+
+	d := *SQLite
+	d.Dialect = returningSQLite{}
+	elt := &Tag{Name: "dialect"}
+	if err := d.Insert(db, "tag", elt); err != nil {
+		t.Fatalf("Insert error: %v", err)
+	}
+	if elt.ID == 0 {
+		t.Errorf("Insert: expected a new key to be read back with RETURNING")
+	}
+
+	// the field is ignored when a Dialect is set
+	d.Placeholder = "$1"
+	loaded := new(Tag)
+	if err := d.Load(db, "tag", loaded, elt.ID); err != nil {
+		t.Errorf("Load error: %v", err)
+	} else if loaded.Name != "dialect" {
+		t.Errorf("Load: expected name dialect, found %s", loaded.Name)
+	}
+	db.Exec("delete from tag")
+}
+
+func TestSQLServer(t *testing.T) {
+	fake := new(fakeDB)
+	sqlDB := fake.open()
+	defer sqlDB.Close()
+
+	// keys come back from the OUTPUT clause
+	fake.queue([]string{"id"}, []driver.Value{int64(42)})
+	elt := &Tag{Name: "go", Uses: 1}
+	if err := SQLServer.Insert(sqlDB, "tag", elt); err != nil {
+		t.Fatalf("Insert error: %v", err)
+	}
+	if elt.ID != 42 {
+		t.Errorf("Insert: expected ID 42, found %d", elt.ID)
+	}
+
+	fake.queue([]string{"id", "name", "uses"}, []driver.Value{int64(42), "go", int64(1)})
+	loaded := new(Tag)
+	if err := SQLServer.Load(sqlDB, "tag", loaded, 42); err != nil {
+		t.Fatalf("Load error: %v", err)
+	}
+
+	elt.Uses = 2
+	if err := SQLServer.Update(sqlDB, "tag", elt); err != nil {
+		t.Fatalf("Update error: %v", err)
+	}
+
+	fake.queue([]string{"id"}, []driver.Value{int64(43)})
+	fake.queue([]string{"id"}, []driver.Value{int64(44)})
+	tags := []*Tag{{Name: "sql"}, {Name: "server"}}
+	if err := SQLServer.InsertAll(sqlDB, "tag", tags); err != nil {
+		t.Fatalf("InsertAll error: %v", err)
+	}
+	if tags[0].ID != 43 || tags[1].ID != 44 {
+		t.Errorf("InsertAll: expected IDs 43 and 44, found %d and %d", tags[0].ID, tags[1].ID)
+	}
+
+	if err := SQLServer.Delete(sqlDB, "tag", elt); err != nil {
+		t.Fatalf("Delete error: %v", err)
+	}
+
+	expected := []string{
+		"INSERT INTO [tag] ([name],[uses]) OUTPUT INSERTED.[id] VALUES (@p1,@p2)",
+		"SELECT [id],[name],[uses] FROM [tag] WHERE [id]=@p1",
+		"UPDATE [tag] SET [name]=@p1,[uses]=@p2 WHERE [id]=@p3",
+		"INSERT INTO [tag] ([name],[uses]) OUTPUT INSERTED.[id] VALUES (@p1,@p2)",
+		"INSERT INTO [tag] ([name],[uses]) OUTPUT INSERTED.[id] VALUES (@p1,@p2)",
+		"DELETE FROM [tag] WHERE [id]=@p1",
+	}
+	if !reflect.DeepEqual(fake.queries, expected) {
+		t.Errorf("expected queries:\n%s\nfound:\n%s", strings.Join(expected, "\n"), strings.Join(fake.queries, "\n"))
+	}
+
+	if err := SQLServer.Upsert(sqlDB, "tag", &Tag{Name: "go"}, "name"); err == nil {
+		t.Errorf("Upsert: expected err, got nil")
+	}
+
+	for _, test := range []struct {
+		limit, offset int
+		expected      string
+	}{
+		{10, 0, " OFFSET 0 ROWS FETCH NEXT 10 ROWS ONLY"},
+		{10, 20, " OFFSET 20 ROWS FETCH NEXT 10 ROWS ONLY"},
+		{-1, 20, " OFFSET 20 ROWS"},
+		{-1, 0, ""},
+	} {
+		if s := SQLServer.LimitOffset(test.limit, test.offset); s != test.expected {
+			t.Errorf("LimitOffset(%d, %d): expected %q, found %q", test.limit, test.offset, test.expected, s)
+		}
+	}
+	if s := SQLServer.LockClause(LockForUpdate); s != "" {
+		t.Errorf("LockClause: expected nothing, found %q", s)
+	}
+
+	// at most 1000 rows go in a single INSERT, even if more placeholders fit
+	type pair struct {
+		A int64 `meddler:"a"`
+		B int64 `meddler:"b"`
+	}
+	fake.queries = nil
+	if err := SQLServer.InsertAll(sqlDB, "pair", make([]pair, 1001)); err != nil {
+		t.Fatalf("InsertAll error: %v", err)
+	}
+	if len(fake.queries) != 2 {
+		t.Fatalf("InsertAll of 1001 rows: expected 2 queries, found %d", len(fake.queries))
+	}
+	if rows := strings.Count(fake.queries[0], "),(") + 1; rows != 1000 {
+		t.Errorf("InsertAll of 1001 rows: expected 1000 rows in the first query, found %d", rows)
+	}
+}
+
+type SequenceTag struct {
+	ID   int64  `meddler:"id,pk,sequence=tag_seq"`
+	Name string `meddler:"name"`
+}
+
+func TestOracle(t *testing.T) {
+	fake := new(fakeDB)
+	sqlDB := fake.open()
+	defer sqlDB.Close()
+
+	// identity keys come back through an output parameter
+	fake.outs = append(fake.outs, []interface{}{int64(42)})
+	elt := &Tag{Name: "go", Uses: 1}
+	if err := Oracle.Insert(sqlDB, "tag", elt); err != nil {
+		t.Fatalf("Insert error: %v", err)
+	}
+	if elt.ID != 42 {
+		t.Errorf("Insert: expected ID 42, found %d", elt.ID)
+	}
+
+	// sequence keys are fetched first and inserted with the row
+	fake.queue([]string{"NEXTVAL"}, []driver.Value{int64(7)})
+	seq := &SequenceTag{Name: "oracle"}
+	if err := Oracle.Insert(sqlDB, "tag", seq); err != nil {
+		t.Fatalf("Insert with sequence error: %v", err)
+	}
+	if seq.ID != 7 {
+		t.Errorf("Insert with sequence: expected ID 7, found %d", seq.ID)
+	}
+
+	// upper case result columns are matched to the struct
+	fake.queue([]string{"ID", "NAME", "USES"}, []driver.Value{int64(42), "go", int64(1)})
+	loaded := new(Tag)
+	if err := Oracle.Load(sqlDB, "tag", loaded, 42); err != nil {
+		t.Fatalf("Load error: %v", err)
+	}
+	if loaded.ID != 42 || loaded.Name != "go" || loaded.Uses != 1 {
+		t.Errorf("Load: expected 42, go, 1, found %d, %s, %d", loaded.ID, loaded.Name, loaded.Uses)
+	}
+
+	// rows go in one at a time
+	fake.outs = append(fake.outs, []interface{}{int64(43)}, []interface{}{int64(44)})
+	tags := []*Tag{{Name: "sql"}, {Name: "plsql"}}
+	if err := Oracle.InsertAll(sqlDB, "tag", tags); err != nil {
+		t.Fatalf("InsertAll error: %v", err)
+	}
+	if tags[0].ID != 43 || tags[1].ID != 44 {
+		t.Errorf("InsertAll: expected IDs 43 and 44, found %d and %d", tags[0].ID, tags[1].ID)
+	}
+
+	expected := []string{
+		`INSERT INTO "TAG" ("NAME","USES") VALUES (:1,:2) RETURNING "ID" INTO :3`,
+		`SELECT "TAG_SEQ".NEXTVAL FROM DUAL`,
+		`INSERT INTO "TAG" ("ID","NAME") VALUES (:1,:2)`,
+		`SELECT "ID","NAME","USES" FROM "TAG" WHERE "ID"=:1`,
+		`INSERT INTO "TAG" ("NAME","USES") VALUES (:1,:2) RETURNING "ID" INTO :3`,
+		`INSERT INTO "TAG" ("NAME","USES") VALUES (:1,:2) RETURNING "ID" INTO :3`,
+	}
+	if !reflect.DeepEqual(fake.queries, expected) {
+		t.Errorf("expected queries:\n%s\nfound:\n%s", strings.Join(expected, "\n"), strings.Join(fake.queries, "\n"))
+	}
+	if len(fake.args) > 2 && !reflect.DeepEqual(fake.args[2], []interface{}{int64(7), "oracle"}) {
+		t.Errorf("Insert with sequence: expected args 7, oracle, found %v", fake.args[2])
+	}
+
+	// sequences need a dialect that supports them
+	if err := SQLite.Insert(sqlDB, "tag", &SequenceTag{Name: "sqlite"}); err == nil {
+		t.Errorf("Insert with sequence on SQLite: expected err, got nil")
+	}
+	type badSequence struct {
+		ID   string `meddler:"id,pk,sequence=tag_seq"`
+		Name string `meddler:"name"`
+	}
+	if _, err := getFields(reflect.TypeOf(&badSequence{})); err == nil {
+		t.Errorf("getFields with sequence on a string key: expected err, got nil")
+	}
+
+	if s := Oracle.LimitOffset(10, 20); s != " OFFSET 20 ROWS FETCH NEXT 10 ROWS ONLY" {
+		t.Errorf("LimitOffset: found %q", s)
+	}
+}
+
+func TestQuoteIdentifier(t *testing.T) {
+	tests := []struct {
+		d        *Database
+		name     string
+		expected string
+	}{
+		{MySQL, "analytics.events", "`analytics`.`events`"},
+		{MySQL, "odd`name", "`odd``name`"},
+		{PostgreSQL, "analytics.events", `"analytics"."events"`},
+		{PostgreSQL, `x"; DROP TABLE person; --`, `"x""; DROP TABLE person; --"`},
+		{SQLServer, "dbo.tag", "[dbo].[tag]"},
+		{SQLServer, "odd]name", "[odd]]name]"},
+		{Oracle, "app.tag", `"APP"."TAG"`},
+		{Oracle, "CamelCase", `"CamelCase"`},
+		{Oracle, "PERSON", `"PERSON"`},
+		{&Database{Quote: `"`}, `a"b.c`, `"a""b"."c"`},
+		{&Database{}, "plain", "plain"},
+	}
+	for _, test := range tests {
+		if s := test.d.QuoteTable(test.name); s != test.expected {
+			t.Errorf("QuoteTable(%s): expected %s, found %s", test.name, test.expected, s)
+		}
+	}
+
+	// only table names are split
+	if s := PostgreSQL.QuoteIdentifier("a.b"); s != `"a.b"` {
+		t.Errorf("QuoteIdentifier(a.b): expected \"a.b\", found %s", s)
+	}
+	if s := Oracle.dialect().(SequenceDialect).NextValue("app.tag_seq"); s != `SELECT "APP"."TAG_SEQ".NEXTVAL FROM DUAL` {
+		t.Errorf("NextValue(app.tag_seq): found %s", s)
+	}
+
+	for name, valid := range map[string]bool{
+		"tag":        true,
+		"main.tag":   true,
+		"_x1":        true,
+		"1x":         false,
+		"tag.":       false,
+		"":           false,
+		"tag; drop":  false,
+		`t"ag`:       false,
+		"ünïcode":    false,
+		"schema.t_2": true,
+	} {
+		if validIdentifier(name) != valid {
+			t.Errorf("validIdentifier(%q): expected %v", name, valid)
+		}
+	}
+}
+
+func TestStrictIdentifiers(t *testing.T) {
+	once.Do(setup)
+
+	d := *SQLite
+	d.StrictIdentifiers = true
+
+	// schema-qualified names are quoted part by part
+	elt := &Tag{Name: "strict"}
+	if err := d.Insert(db, "main.tag", elt); err != nil {
+		t.Fatalf("Insert into main.tag error: %v", err)
+	}
+	loaded := new(Tag)
+	if err := d.Load(db, "main.tag", loaded, elt.ID); err != nil {
+		t.Errorf("Load from main.tag error: %v", err)
+	}
+
+	if err := d.Insert(db, `tag" (name) values ('x'); --`, &Tag{Name: "bad"}); !errors.Is(err, ErrInvalidIdentifier) {
+		t.Errorf("Insert with bad table name: expected ErrInvalidIdentifier, got %v", err)
+	}
+	type badColumn struct {
+		ID   int64  `meddler:"id,pk"`
+		Name string `meddler:"name,zeroisnull"`
+		Bad  string `meddler:"bad column"`
+	}
+	if err := d.Update(db, "tag", &badColumn{ID: elt.ID}); !errors.Is(err, ErrInvalidIdentifier) {
+		t.Errorf("Update with bad column name: expected ErrInvalidIdentifier, got %v", err)
+	}
+	if err := d.Delete(db, "tag;", elt); !errors.Is(err, ErrInvalidIdentifier) {
+		t.Errorf("Delete with bad table name: expected ErrInvalidIdentifier, got %v", err)
+	}
+	db.Exec("delete from tag")
+}
@@ -0,0 +1,210 @@
+package meddler
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// ErrNoRowsAffected is returned by Delete and its variants
+// when no database row matched the primary key.
+var ErrNoRowsAffected = errors.New("meddler: no rows affected")
+
+// ErrStaleObject is returned by Update and its variants when the struct
+// has a version field and the database row has been changed since the
+// struct was loaded, or has been deleted.
+var ErrStaleObject = errors.New("meddler: stale object")
+
+// These errors are wrapped with the name of the function that found the
+// problem, so use errors.Is to check for them.
+var (
+	// ErrNoPrimaryKey means the struct has no field marked as pk.
+	ErrNoPrimaryKey = errors.New("no primary key field")
+
+	// ErrCompositePrimaryKey means the operation needs a single primary
+	// key, but the struct has several fields marked as pk.
+	ErrCompositePrimaryKey = errors.New("struct has a composite primary key")
+
+	// ErrPrimaryKeyNotZero means an integer primary key was already set
+	// for an insert, which needs the database to allocate a new key.
+	ErrPrimaryKeyNotZero = errors.New("primary key must be zero")
+
+	// ErrPrimaryKeyNotSet means the primary key was zero for an
+	// operation that needs an existing row, such as Update.
+	ErrPrimaryKeyNotSet = errors.New("primary key is not set")
+
+	// ErrPrimaryKeyNotInteger means PrimaryKey or SetPrimaryKey was used
+	// with a key that is not an integer. Use PrimaryKeyValue and
+	// SetPrimaryKeyValue for other key types.
+	ErrPrimaryKeyNotInteger = errors.New("primary key is not an integer")
+
+	// ErrKeyCount means the number of key values given to LoadByKey or
+	// DeleteByKey does not match the number of primary key fields, or
+	// the database returned the wrong number of new keys to InsertAll.
+	ErrKeyCount = errors.New("wrong number of key values")
+
+	// ErrColumnNotFound means a column was named that does not belong to
+	// the struct. It is wrapped in a *FieldError.
+	ErrColumnNotFound = errors.New("column not found in struct")
+
+	// ErrColumnNotUpdatable means a column was named more than once, or
+	// names the primary key or the version, in a list of columns to
+	// update. It is wrapped in a *FieldError.
+	ErrColumnNotUpdatable = errors.New("column cannot be updated")
+
+	// ErrMeddlerNotRegistered means a struct tag names a meddler that
+	// has not been registered. It is wrapped in a *ConfigError.
+	ErrMeddlerNotRegistered = errors.New("meddler not registered")
+
+	// ErrRuleNotRegistered means a validate tag option names a rule
+	// that has not been registered. It is wrapped in a *ConfigError.
+	ErrRuleNotRegistered = errors.New("validation rule not registered")
+
+	// ErrInvalidIdentifier means a table or column name was rejected
+	// because the Database has StrictIdentifiers set.
+	ErrInvalidIdentifier = errors.New("invalid identifier")
+
+	// ErrNotSupported means the operation needs a feature that the
+	// database does not have, such as Upsert on SQL Server or a primary
+	// key that cannot be read back after an insert.
+	ErrNotSupported = errors.New("not supported by this database")
+
+	// ErrNoConflictColumns means Upsert was not told which columns
+	// detect a conflict, and the primary key cannot be used instead.
+	ErrNoConflictColumns = errors.New("no conflict columns given")
+
+	// ErrParameterNotFound means a named parameter in a query has no
+	// value in the struct or map given for it.
+	ErrParameterNotFound = errors.New("no value for named parameter")
+)
+
+// QueryError is returned when the database reports an error for a query,
+// or for the result of one. Err is the error returned by the driver.
+type QueryError struct {
+	Op    string // the function that ran the query, e.g. "meddler.Insert"
+	Table string // the table, if the function was given one
+	SQL   string // the query, if it is known
+	Err   error
+}
+
+func (err *QueryError) Error() string {
+	if err.Table != "" {
+		return fmt.Sprintf("%s: DB error on table %s: %v", err.Op, err.Table, err.Err)
+	}
+	return fmt.Sprintf("%s: DB error: %v", err.Op, err.Err)
+}
+
+func (err *QueryError) Unwrap() error {
+	return err.Err
+}
+
+// ScanError is returned when a row read from the database cannot be
+// stored in the struct, such as a NULL in a column whose field cannot
+// hold one. Err is the error returned by Scan in the database/sql
+// package; unlike a QueryError, it does not come from the driver.
+type ScanError struct {
+	Op  string // the function that scanned the row, e.g. "meddler.Scan"
+	Err error
+}
+
+func (err *ScanError) Error() string {
+	return fmt.Sprintf("%s: scan error: %v", err.Op, err.Err)
+}
+
+func (err *ScanError) Unwrap() error {
+	return err.Err
+}
+
+// FieldError is returned when a meddler fails to convert a struct field,
+// or when a column named in a call cannot be used, as in Upsert or
+// UpdateColumns. In the latter case Meddler is empty, and Field is empty
+// too if the column does not belong to the struct.
+type FieldError struct {
+	Op      string // the meddler method that failed: "PreRead", "PostRead", or "PreWrite", or the function, e.g. "meddler.UpdateColumns"
+	Field   string // the struct field, with embedded struct names, e.g. "Address.Street"
+	Column  string // the database column
+	Meddler string // the name the meddler was registered under
+	Err     error
+}
+
+func (err *FieldError) Error() string {
+	switch {
+	case err.Meddler != "":
+		return fmt.Sprintf("meddler: %s error on column [%s] (field %s, meddler %s): %v", err.Op, err.Column, err.Field, err.Meddler, err.Err)
+	case err.Field != "":
+		return fmt.Sprintf("%s: column [%s] (field %s): %v", err.Op, err.Column, err.Field, err.Err)
+	default:
+		return fmt.Sprintf("%s: column [%s]: %v", err.Op, err.Column, err.Err)
+	}
+}
+
+func (err *FieldError) Unwrap() error {
+	return err.Err
+}
+
+// ConfigError is returned when a struct cannot be used with meddler,
+// usually because of a problem with its tags.
+type ConfigError struct {
+	Type  reflect.Type // the struct type, if known
+	Field string       // the struct field with the problem, if there is one
+	Msg   string       // a description of the problem
+	Err   error        // the underlying error, such as ErrMeddlerNotRegistered, or nil
+}
+
+func (err *ConfigError) Error() string {
+	var parts []string
+	if err.Type != nil {
+		parts = append(parts, err.Type.String())
+	}
+	if err.Field != "" {
+		parts = append(parts, "field "+err.Field)
+	}
+	parts = append(parts, err.Msg)
+	if err.Err != nil {
+		parts = append(parts, err.Err.Error())
+	}
+	return "meddler: " + strings.Join(parts, ": ")
+}
+
+func (err *ConfigError) Unwrap() error {
+	return err.Err
+}
+
+// DriverErr returns the original error as returned by the database driver
+// if the error comes from the driver, with the second value set to true.
+// Otherwise, it returns err itself with false as second value.
+func DriverErr(err error) (error, bool) {
+	var qerr *QueryError
+	if errors.As(err, &qerr) {
+		return qerr.Err, true
+	}
+	return err, false
+}
+
+// fieldError wraps an error returned by the meddler of a field.
+func fieldError(op string, field *structField, err error) error {
+	return &FieldError{
+		Op:      op,
+		Field:   field.name,
+		Column:  field.column,
+		Meddler: field.meddlerName,
+		Err:     err,
+	}
+}
+
+// fieldName returns the name of the struct field at index, including the
+// names of the embedded structs it is found in.
+func fieldName(structType reflect.Type, index []int) string {
+	var names []string
+	t := structType
+	for _, x := range index {
+		if t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		f := t.Field(x)
+		names = append(names, f.Name)
+		t = f.Type
+	}
+	return strings.Join(names, ".")
+}
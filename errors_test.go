@@ -0,0 +1,188 @@
+package meddler
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var errFailingMeddler = errors.New("failing meddler")
+
+type FailingMeddler struct {
+	IdentityMeddler
+}
+
+func (m FailingMeddler) PreWrite(field interface{}) (interface{}, error) {
+	return nil, errFailingMeddler
+}
+
+func init() {
+	Register("failing", FailingMeddler{})
+}
+
+func TestErrorTypes(t *testing.T) {
+	once.Do(setup)
+
+	// driver errors come with the query
+	elt := &Tag{Name: "errors"}
+	err := Insert(db, "nosuchtable", elt)
+	var qerr *QueryError
+	if !errors.As(err, &qerr) {
+		t.Fatalf("Insert into missing table: expected *QueryError, got %v", err)
+	}
+	if qerr.Op != "meddler.Insert" || qerr.Table != "nosuchtable" || !strings.HasPrefix(qerr.SQL, "INSERT INTO `nosuchtable`") {
+		t.Errorf("Insert into missing table: found op %q, table %q, SQL %q", qerr.Op, qerr.Table, qerr.SQL)
+	}
+	if driverErr, ok := DriverErr(err); !ok || driverErr != qerr.Err {
+		t.Errorf("DriverErr: expected the driver error, got %v", driverErr)
+	}
+
+	// so do errors from queries the caller wrote
+	var people []*Person
+	err = QueryAll(db, &people, "select * from nosuchtable where id = ?", 1)
+	if !errors.As(err, &qerr) || qerr.Op != "meddler.QueryAll" || qerr.SQL != "select * from nosuchtable where id = ?" {
+		t.Errorf("QueryAll from missing table: expected *QueryError with the query, got %v", err)
+	}
+	_, err = Get[Person](context.Background(), db, "select * from nosuchtable")
+	if !errors.As(err, &qerr) || qerr.Op != "meddler.Get" {
+		t.Errorf("Get from missing table: expected *QueryError, got %v", err)
+	}
+	_, err = NamedExec(db, "delete from nosuchtable where id = :id", map[string]interface{}{"id": 1})
+	if !errors.As(err, &qerr) || qerr.Op != "meddler.NamedExec" {
+		t.Errorf("NamedExec on missing table: expected *QueryError, got %v", err)
+	}
+
+	// values that do not fit the struct are not driver errors
+	err = QueryRow(db, new(Person), "select NULL as name")
+	var serr *ScanError
+	if !errors.As(err, &serr) || serr.Op != "meddler.Scan" {
+		t.Errorf("QueryRow with NULL name: expected *ScanError, got %v", err)
+	}
+	if _, ok := DriverErr(err); ok {
+		t.Errorf("DriverErr on a scan error: expected ok to be false")
+	}
+
+	// sentinel errors
+	elt.ID = 5
+	if err := Insert(db, "tag", elt); !errors.Is(err, ErrPrimaryKeyNotZero) {
+		t.Errorf("Insert with key set: expected ErrPrimaryKeyNotZero, got %v", err)
+	}
+	elt.ID = 0
+	if err := Update(db, "tag", elt); !errors.Is(err, ErrPrimaryKeyNotSet) {
+		t.Errorf("Update with zero key: expected ErrPrimaryKeyNotSet, got %v", err)
+	}
+	type noKey struct {
+		Name string `meddler:"name"`
+	}
+	if err := Update(db, "tag", &noKey{}); !errors.Is(err, ErrNoPrimaryKey) {
+		t.Errorf("Update without key: expected ErrNoPrimaryKey, got %v", err)
+	}
+	if err := Save(db, "membership", &Membership{PersonID: 1, GroupID: 1}); !errors.Is(err, ErrCompositePrimaryKey) {
+		t.Errorf("Save with composite key: expected ErrCompositePrimaryKey, got %v", err)
+	}
+	if err := LoadByKey(db, "membership", new(Membership), 1); !errors.Is(err, ErrKeyCount) {
+		t.Errorf("LoadByKey with one of two keys: expected ErrKeyCount, got %v", err)
+	}
+	if err := DeleteByKey(db, "membership", new(Membership), 1, 2, 3); !errors.Is(err, ErrKeyCount) {
+		t.Errorf("DeleteByKey with three of two keys: expected ErrKeyCount, got %v", err)
+	}
+	type stringKey struct {
+		Code string `meddler:"code,pk"`
+	}
+	if err := SQLServer.Upsert(db, "tag", elt, "name"); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("Upsert on SQL Server: expected ErrNotSupported, got %v", err)
+	}
+	if err := PostgreSQL.UpsertContext(context.Background(), &recordingDB{}, "tag", &Tag{Name: "x"}); !errors.Is(err, ErrNoConflictColumns) {
+		t.Errorf("Upsert without conflict columns: expected ErrNoConflictColumns, got %v", err)
+	}
+	if _, err := NamedExec(db, "delete from tag where id = :id", map[string]interface{}{}); !errors.Is(err, ErrParameterNotFound) {
+		t.Errorf("NamedExec without a value: expected ErrParameterNotFound, got %v", err)
+	}
+	if _, _, err := PrimaryKey(&stringKey{Code: "x"}); !errors.Is(err, ErrPrimaryKeyNotInteger) {
+		t.Errorf("PrimaryKey with string key: expected ErrPrimaryKeyNotInteger, got %v", err)
+	}
+	if err := SetPrimaryKey(&stringKey{}, 1); !errors.Is(err, ErrPrimaryKeyNotInteger) {
+		t.Errorf("SetPrimaryKey with string key: expected ErrPrimaryKeyNotInteger, got %v", err)
+	}
+
+	// columns that cannot be used
+	elt.ID = 1
+	err = UpdateColumns(db, "tag", elt, "nosuchcolumn")
+	var ferr *FieldError
+	if !errors.As(err, &ferr) || ferr.Column != "nosuchcolumn" || !errors.Is(err, ErrColumnNotFound) {
+		t.Errorf("UpdateColumns with unknown column: expected *FieldError wrapping ErrColumnNotFound, got %v", err)
+	}
+	err = UpdateColumns(db, "tag", elt, "id")
+	if !errors.As(err, &ferr) || ferr.Field != "ID" || !errors.Is(err, ErrColumnNotUpdatable) {
+		t.Errorf("UpdateColumns with key column: expected *FieldError wrapping ErrColumnNotUpdatable, got %v", err)
+	}
+	elt.ID = 0
+
+	// tag problems
+	type unknownMeddler struct {
+		ID   int64  `meddler:"id,pk"`
+		Name string `meddler:"name,nosuchmeddler"`
+	}
+	err = Insert(db, "tag", &unknownMeddler{})
+	var cerr *ConfigError
+	if !errors.As(err, &cerr) {
+		t.Fatalf("Insert with unknown meddler: expected *ConfigError, got %v", err)
+	}
+	if cerr.Field != "Name" || !errors.Is(err, ErrMeddlerNotRegistered) {
+		t.Errorf("Insert with unknown meddler: found field %q, err %v", cerr.Field, cerr.Err)
+	}
+	if err := Restore(db, "tag", &Tag{ID: 1}); !errors.As(err, &cerr) {
+		t.Errorf("Restore without softdelete field: expected *ConfigError, got %v", err)
+	}
+	var notSlice []Person
+	err = QueryAll(db, &notSlice, "select * from person")
+	if !errors.As(err, &cerr) || cerr.Type != reflect.TypeOf(&notSlice) {
+		t.Errorf("QueryAll into slice of structs: expected *ConfigError, got %v", err)
+	}
+	if err := InsertAll(db, "tag", &Tag{}); !errors.As(err, &cerr) {
+		t.Errorf("InsertAll with non-slice: expected *ConfigError, got %v", err)
+	}
+	if err := UpdateColumns(db, "tag", &Tag{ID: 1}); !errors.As(err, &cerr) {
+		t.Errorf("UpdateColumns with no columns: expected *ConfigError, got %v", err)
+	}
+
+	// meddler failures
+	type failingTag struct {
+		ID    int64  `meddler:"id,pk"`
+		Extra string `meddler:"extra,failing"`
+	}
+	_, err = Values(&failingTag{}, true)
+	if !errors.As(err, &ferr) {
+		t.Fatalf("Values with failing meddler: expected *FieldError, got %v", err)
+	}
+	if ferr.Op != "PreWrite" || ferr.Column != "extra" || ferr.Field != "Extra" || ferr.Meddler != "failing" {
+		t.Errorf("Values with failing meddler: found op %q, column %q, field %q, meddler %q", ferr.Op, ferr.Column, ferr.Field, ferr.Meddler)
+	}
+	if !errors.Is(err, errFailingMeddler) {
+		t.Errorf("Values with failing meddler: expected to unwrap to the meddler error, got %v", err)
+	}
+}
+
+func TestFieldName(t *testing.T) {
+	data, err := getFields(reflect.TypeOf((*Contact)(nil)))
+	if err != nil {
+		t.Fatalf("getFields error: %v", err)
+	}
+	for column, expected := range map[string]string{
+		"id":          "ID",
+		"created":     "Timestamps.Created",
+		"created_by":  "AuditInfo.CreatedBy",
+		"addr_street": "Addr.Street",
+	} {
+		field, present := data.fields[column]
+		if !present {
+			t.Errorf("column %s not found", column)
+			continue
+		}
+		if field.name != expected {
+			t.Errorf("column %s: expected field name %s, found %s", column, expected, field.name)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package meddler
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+)
+
+// fakeDB is a database/sql driver that records every statement it is
+// given, so the SQL generated for a database without a server can be
+// checked exactly. Queries are answered from the rows queued by the test,
+// and output parameters are filled in from the queued outs.
+type fakeDB struct {
+	queries []string
+	args    [][]interface{}
+	rows    []fakeRows
+	outs    [][]interface{}
+	lastID  int64
+}
+
+type fakeRows struct {
+	columns []string
+	values  [][]driver.Value
+}
+
+// open returns a *sql.DB backed by f, limited to a single connection.
+func (f *fakeDB) open() *sql.DB {
+	sqlDB := sql.OpenDB(fakeConnector{f})
+	sqlDB.SetMaxOpenConns(1)
+	return sqlDB
+}
+
+// queue adds the result of a query that returns the given rows.
+func (f *fakeDB) queue(columns []string, values ...[]driver.Value) {
+	f.rows = append(f.rows, fakeRows{columns: columns, values: values})
+}
+
+// record saves a statement and its arguments, and fills in any
+// output parameters.
+func (f *fakeDB) record(query string, args []driver.NamedValue) error {
+	var list []interface{}
+	var outs []interface{}
+	for _, arg := range args {
+		if out, ok := arg.Value.(sql.Out); ok {
+			outs = append(outs, out.Dest)
+		}
+		list = append(list, arg.Value)
+	}
+	f.queries = append(f.queries, query)
+	f.args = append(f.args, list)
+	if len(outs) == 0 {
+		return nil
+	}
+	if len(f.outs) == 0 {
+		return errors.New("fakeDB: no output values queued")
+	}
+	values := f.outs[0]
+	f.outs = f.outs[1:]
+	for i, dest := range outs {
+		reflect.ValueOf(dest).Elem().Set(reflect.ValueOf(values[i]))
+	}
+	return nil
+}
+
+type fakeConnector struct {
+	f *fakeDB
+}
+
+func (c fakeConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	return fakeConn(c), nil
+}
+
+func (c fakeConnector) Driver() driver.Driver {
+	return fakeDriver{}
+}
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(name string) (driver.Conn, error) {
+	return nil, errors.New("fakeDB: use fakeDB.open")
+}
+
+type fakeConn struct {
+	f *fakeDB
+}
+
+func (c fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("fakeDB: prepared statements are not supported")
+}
+
+func (c fakeConn) Close() error {
+	return nil
+}
+
+func (c fakeConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("fakeDB: transactions are not supported")
+}
+
+// CheckNamedValue accepts every argument, including sql.Out.
+func (c fakeConn) CheckNamedValue(nv *driver.NamedValue) error {
+	if _, ok := nv.Value.(sql.Out); ok {
+		return nil
+	}
+	value, err := driver.DefaultParameterConverter.ConvertValue(nv.Value)
+	if err != nil {
+		return err
+	}
+	nv.Value = value
+	return nil
+}
+
+func (c fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if err := c.f.record(query, args); err != nil {
+		return nil, err
+	}
+	return fakeResult(c.f.lastID), nil
+}
+
+func (c fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if err := c.f.record(query, args); err != nil {
+		return nil, err
+	}
+	if len(c.f.rows) == 0 {
+		return nil, fmt.Errorf("fakeDB: no rows queued for %s", query)
+	}
+	rows := c.f.rows[0]
+	c.f.rows = c.f.rows[1:]
+	return &rows, nil
+}
+
+type fakeResult int64
+
+func (r fakeResult) LastInsertId() (int64, error) { return int64(r), nil }
+func (r fakeResult) RowsAffected() (int64, error) { return 1, nil }
+
+func (r *fakeRows) Columns() []string {
+	return r.columns
+}
+
+func (r *fakeRows) Close() error {
+	return nil
+}
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
@@ -0,0 +1,74 @@
+package meddler
+
+import (
+	"context"
+	"database/sql"
+	"reflect"
+)
+
+// Get performs the given query with the given arguments, scanning a single
+// row of results into a newly-allocated T, which must be a struct type;
+// this is checked when it runs, since Go cannot express it as a constraint.
+// Returns sql.ErrNoRows if there was no result row.
+// It uses the Default Database type.
+func Get[T any](ctx context.Context, db DBContext, query string, args ...interface{}) (*T, error) {
+	query, args = Default.userQuery(query, args)
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, &QueryError{Op: "meddler.Get", SQL: query, Err: err}
+	}
+	defer rows.Close()
+
+	elts, err := scanTyped[T](ctx, Default, rows, 1)
+	if err != nil {
+		return nil, err
+	}
+	if len(elts) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	if err := rows.Close(); err != nil {
+		return nil, &QueryError{Op: "meddler.Get", SQL: query, Err: err}
+	}
+	return elts[0], nil
+}
+
+// Select performs the given query with the given arguments, scanning
+// all result rows into a slice of newly-allocated T values, where T must
+// be a struct type, as for Get. An empty result set gives an empty slice,
+// not an error.
+// It uses the Default Database type.
+func Select[T any](ctx context.Context, db DBContext, query string, args ...interface{}) ([]*T, error) {
+	query, args = Default.userQuery(query, args)
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, &QueryError{Op: "meddler.Select", SQL: query, Err: err}
+	}
+	defer rows.Close()
+
+	return scanTyped[T](ctx, Default, rows, -1)
+}
+
+// LoadByPK loads a record of type T, which must be a struct type as for
+// Get, using a query for the primary key field.
+// Returns sql.ErrNoRows if not found.
+// It uses the Default Database type.
+func LoadByPK[T any](ctx context.Context, db DBContext, table string, pk int64) (*T, error) {
+	dst := new(T)
+	if err := Default.LoadContext(ctx, db, table, dst, pk); err != nil {
+		return nil, err
+	}
+	return dst, nil
+}
+
+// scanTyped scans up to limit rows into newly-allocated T values.
+// A negative limit reads every row. It does not close rows.
+func scanTyped[T any](ctx context.Context, d *Database, rows *sql.Rows, limit int) ([]*T, error) {
+	elts := []*T{}
+	err := d.scanRows(ctx, rows, reflect.TypeOf((*T)(nil)), limit, func(eltVal reflect.Value) {
+		elts = append(elts, eltVal.Interface().(*T))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return elts, nil
+}
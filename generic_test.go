@@ -0,0 +1,73 @@
+package meddler
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+)
+
+func TestGet(t *testing.T) {
+	once.Do(setup)
+	insertAliceBob(t)
+	ctx := context.Background()
+
+	elt, err := Get[Person](ctx, db, "select * from person where id = ?", 1)
+	if err != nil {
+		t.Fatalf("Get error on Alice: %v", err)
+	}
+	height := 65
+	personEqual(t, elt, &Person{1, "Alice", 0, "alice@alice.com", 0, 32, when, when, &when, &height})
+
+	if _, err := Get[Person](ctx, db, "select * from person where id = ?", 99); err != sql.ErrNoRows {
+		t.Errorf("Get on missing row: expected sql.ErrNoRows, got %v", err)
+	}
+	if _, err := Get[string](ctx, db, "select name from person"); err == nil {
+		t.Errorf("Get with non-struct type: expected err, got nil")
+	}
+	db.Exec("delete from person")
+}
+
+func TestSelect(t *testing.T) {
+	once.Do(setup)
+	insertAliceBob(t)
+	ctx := context.Background()
+
+	lst, err := Select[Person](ctx, db, "select * from person order by id")
+	if err != nil {
+		t.Fatalf("Select error: %v", err)
+	}
+	if len(lst) != 2 {
+		t.Fatalf("Select found %d rows, expected 2", len(lst))
+	}
+	height := 65
+	personEqual(t, lst[0], &Person{1, "Alice", 0, "alice@alice.com", 0, 32, when, when, &when, &height})
+	personEqual(t, lst[1], &Person{2, "Bob", 0, "bob@bob.com", 0, 0, when, time.Time{}, nil, nil})
+
+	lst, err = Select[Person](ctx, db, "select * from person where id > ?", 10)
+	if err != nil {
+		t.Errorf("Select error on empty result: %v", err)
+	}
+	if lst == nil || len(lst) != 0 {
+		t.Errorf("Select on empty result: expected empty slice, got %v", lst)
+	}
+	db.Exec("delete from person")
+}
+
+func TestLoadByPK(t *testing.T) {
+	once.Do(setup)
+	insertAliceBob(t)
+	ctx := context.Background()
+
+	elt, err := LoadByPK[Person](ctx, db, "person", 2)
+	if err != nil {
+		t.Fatalf("LoadByPK error on Bob: %v", err)
+	}
+	bob.ID = 2
+	personEqual(t, elt, bob)
+
+	if _, err := LoadByPK[Person](ctx, db, "person", 99); err != sql.ErrNoRows {
+		t.Errorf("LoadByPK on missing row: expected sql.ErrNoRows, got %v", err)
+	}
+	db.Exec("delete from person")
+}
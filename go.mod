@@ -1,5 +1,5 @@
 module github.com/russross/meddler
 
-go 1.13
+go 1.18
 
 require github.com/mattn/go-sqlite3 v1.14.7
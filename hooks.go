@@ -0,0 +1,104 @@
+package meddler
+
+import (
+	"context"
+)
+
+// BeforeInserter is implemented by structs that want to be called by Insert
+// and InsertAll before a new row is written, e.g. to normalise or validate
+// their fields. A non-nil error aborts the insert and is returned as is.
+type BeforeInserter interface {
+	BeforeInsert(ctx context.Context, db DBContext) error
+}
+
+// AfterInserter is implemented by structs that want to be called by Insert
+// and InsertAll after their row has been written and a new primary key has
+// been stored in the struct. A non-nil error is returned to the caller, but
+// the row has already been inserted.
+type AfterInserter interface {
+	AfterInsert(ctx context.Context, db DBContext) error
+}
+
+// BeforeUpdater is implemented by structs that want to be called by Update
+// and its variants before the row is written. A non-nil error aborts the
+// update and is returned as is.
+type BeforeUpdater interface {
+	BeforeUpdate(ctx context.Context, db DBContext) error
+}
+
+// AfterUpdater is implemented by structs that want to be called by Update
+// and its variants after the row has been written. A non-nil error is
+// returned to the caller, but the row has already been updated.
+type AfterUpdater interface {
+	AfterUpdate(ctx context.Context, db DBContext) error
+}
+
+// BeforeSaver is implemented by structs that want to be called by Save and
+// Upsert before either an insert or an update. In Save it runs before
+// BeforeInsert or BeforeUpdate. Upsert calls no other hook, since it cannot
+// tell which of the two will happen. A non-nil error aborts the operation.
+type BeforeSaver interface {
+	BeforeSave(ctx context.Context, db DBContext) error
+}
+
+// AfterLoader is implemented by structs that want to be called by Load and
+// LoadByKey after the row has been read, e.g. to fill in derived fields.
+type AfterLoader interface {
+	AfterLoad(ctx context.Context, db DBContext) error
+}
+
+// AfterScanner is implemented by structs that want to be called whenever
+// a row has been scanned into them, including by Load and the Query
+// functions. It is not given the DB, since Scan does not have one.
+type AfterScanner interface {
+	AfterScan(ctx context.Context) error
+}
+
+func beforeInsert(ctx context.Context, db DBContext, src interface{}) error {
+	if h, ok := src.(BeforeInserter); ok {
+		return h.BeforeInsert(ctx, db)
+	}
+	return nil
+}
+
+func afterInsert(ctx context.Context, db DBContext, src interface{}) error {
+	if h, ok := src.(AfterInserter); ok {
+		return h.AfterInsert(ctx, db)
+	}
+	return nil
+}
+
+func beforeUpdate(ctx context.Context, db DBContext, src interface{}) error {
+	if h, ok := src.(BeforeUpdater); ok {
+		return h.BeforeUpdate(ctx, db)
+	}
+	return nil
+}
+
+func afterUpdate(ctx context.Context, db DBContext, src interface{}) error {
+	if h, ok := src.(AfterUpdater); ok {
+		return h.AfterUpdate(ctx, db)
+	}
+	return nil
+}
+
+func beforeSave(ctx context.Context, db DBContext, src interface{}) error {
+	if h, ok := src.(BeforeSaver); ok {
+		return h.BeforeSave(ctx, db)
+	}
+	return nil
+}
+
+func afterLoad(ctx context.Context, db DBContext, dst interface{}) error {
+	if h, ok := dst.(AfterLoader); ok {
+		return h.AfterLoad(ctx, db)
+	}
+	return nil
+}
+
+func afterScan(ctx context.Context, dst interface{}) error {
+	if h, ok := dst.(AfterScanner); ok {
+		return h.AfterScan(ctx)
+	}
+	return nil
+}
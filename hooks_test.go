@@ -0,0 +1,173 @@
+package meddler
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+var errNegativeUses = errors.New("uses must not be negative")
+
+type HookedTag struct {
+	ID    int64  `meddler:"id,pk"`
+	Name  string `meddler:"name"`
+	Uses  int    `meddler:"uses"`
+	Label string `meddler:"-"`
+	calls []string
+	found int
+}
+
+func (elt *HookedTag) BeforeSave(ctx context.Context, db DBContext) error {
+	elt.calls = append(elt.calls, "BeforeSave")
+	return nil
+}
+
+func (elt *HookedTag) BeforeInsert(ctx context.Context, db DBContext) error {
+	elt.calls = append(elt.calls, "BeforeInsert")
+	elt.Name = strings.ToLower(elt.Name)
+	return nil
+}
+
+func (elt *HookedTag) AfterInsert(ctx context.Context, db DBContext) error {
+	elt.calls = append(elt.calls, "AfterInsert")
+
+	// the new row is visible through the same DB
+	return db.QueryRowContext(ctx, "select count(*) from tag where id = ?", elt.ID).Scan(&elt.found)
+}
+
+func (elt *HookedTag) BeforeUpdate(ctx context.Context, db DBContext) error {
+	elt.calls = append(elt.calls, "BeforeUpdate")
+	if elt.Uses < 0 {
+		return errNegativeUses
+	}
+	return nil
+}
+
+func (elt *HookedTag) AfterUpdate(ctx context.Context, db DBContext) error {
+	elt.calls = append(elt.calls, "AfterUpdate")
+	return nil
+}
+
+func (elt *HookedTag) AfterScan(ctx context.Context) error {
+	elt.calls = append(elt.calls, "AfterScan")
+	return nil
+}
+
+func (elt *HookedTag) AfterLoad(ctx context.Context, db DBContext) error {
+	elt.calls = append(elt.calls, "AfterLoad")
+	elt.Label = elt.Name + "#" + strings.Repeat("|", elt.Uses)
+	return nil
+}
+
+func checkCalls(t *testing.T, op string, elt *HookedTag, expected ...string) {
+	if strings.Join(elt.calls, ",") != strings.Join(expected, ",") {
+		t.Errorf("%s: expected hooks %v, found %v", op, expected, elt.calls)
+	}
+	elt.calls = nil
+}
+
+func TestHooks(t *testing.T) {
+	once.Do(setup)
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("Begin error: %v", err)
+	}
+	elt := &HookedTag{Name: "Hooks", Uses: 2}
+	if err := Save(tx, "tag", elt); err != nil {
+		t.Fatalf("Save error: %v", err)
+	}
+	checkCalls(t, "Save", elt, "BeforeSave", "BeforeInsert", "AfterInsert")
+	if elt.Name != "hooks" {
+		t.Errorf("BeforeInsert: expected name hooks, found %s", elt.Name)
+	}
+	if elt.found != 1 {
+		t.Errorf("AfterInsert: expected to find 1 row in the transaction, found %d", elt.found)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit error: %v", err)
+	}
+
+	elt.Uses = -1
+	if err := Update(db, "tag", elt); err != errNegativeUses {
+		t.Errorf("Update with failing hook: expected errNegativeUses, got %v", err)
+	}
+	checkCalls(t, "Update with failing hook", elt, "BeforeUpdate")
+
+	elt.Uses = 3
+	if err := UpdateColumns(db, "tag", elt, "uses"); err != nil {
+		t.Errorf("UpdateColumns error: %v", err)
+	}
+	checkCalls(t, "UpdateColumns", elt, "BeforeUpdate", "AfterUpdate")
+
+	check := new(HookedTag)
+	if err := Load(db, "tag", check, elt.ID); err != nil {
+		t.Fatalf("Load error: %v", err)
+	}
+	checkCalls(t, "Load", check, "AfterScan", "AfterLoad")
+	if check.Label != "hooks#|||" {
+		t.Errorf("AfterLoad: expected label hooks#|||, found %s", check.Label)
+	}
+
+	var all []*HookedTag
+	if err := QueryAll(db, &all, "select * from tag"); err != nil {
+		t.Fatalf("QueryAll error: %v", err)
+	}
+	if len(all) != 1 {
+		t.Fatalf("QueryAll: expected 1 row, found %d", len(all))
+	}
+	checkCalls(t, "QueryAll", all[0], "AfterScan")
+
+	others := []*HookedTag{{Name: "A"}, {Name: "B"}}
+	if err := InsertAll(db, "tag", others); err != nil {
+		t.Fatalf("InsertAll error: %v", err)
+	}
+	for _, other := range others {
+		checkCalls(t, "InsertAll", other, "BeforeInsert", "AfterInsert")
+	}
+
+	// Upsert cannot know which of the other hooks would apply
+	upserted := &HookedTag{Name: "a", Uses: 5}
+	if err := SQLite.Upsert(db, "tag", upserted, "name"); err != nil {
+		t.Fatalf("Upsert error: %v", err)
+	}
+	checkCalls(t, "Upsert", upserted, "BeforeSave")
+	db.Exec("delete from tag")
+}
+
+// TrackedTag counts its updates in a BeforeUpdate hook.
+type TrackedTag struct {
+	Tracked
+	ID   int64  `meddler:"id,pk"`
+	Name string `meddler:"name"`
+	Uses int    `meddler:"uses"`
+}
+
+func (elt *TrackedTag) BeforeUpdate(ctx context.Context, db DBContext) error {
+	elt.Uses++
+	return nil
+}
+
+func TestUpdateChangedHooks(t *testing.T) {
+	once.Do(setup)
+
+	elt := &TrackedTag{Name: "tracked"}
+	if err := Insert(db, "tag", elt); err != nil {
+		t.Fatalf("Insert error: %v", err)
+	}
+
+	// the hook runs even though nothing else changed,
+	// and what it changes is written
+	if err := UpdateChanged(db, "tag", elt); err != nil {
+		t.Fatalf("UpdateChanged error: %v", err)
+	}
+	check := new(TrackedTag)
+	if err := Load(db, "tag", check, elt.ID); err != nil {
+		t.Fatalf("Load error: %v", err)
+	}
+	if elt.Uses != 1 || check.Uses != 1 {
+		t.Errorf("UpdateChanged: expected uses 1 in the struct and the row, found %d and %d", elt.Uses, check.Uses)
+	}
+	db.Exec("delete from tag")
+}
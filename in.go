@@ -0,0 +1,128 @@
+package meddler
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// InList is a list of query arguments that is expanded into one
+// placeholder per value. Use In to make one.
+type InList []interface{}
+
+// In marks a slice or array argument of a hand-written query to be
+// expanded into a placeholder for each of its elements, e.g.
+//
+//	meddler.QueryAll(db, &people, "select * from person where id in (?)", meddler.In(ids))
+//
+// runs "select * from person where id in (?,?,?)" with the elements of
+// ids as the arguments. Slices are only expanded when wrapped this way,
+// so []byte values and slices written by a meddler are passed on as they
+// are. An empty list becomes NULL, so IN matches no rows; beware that
+// NOT IN (NULL) matches no rows either. Any other value is treated as a
+// list of one.
+func In(values interface{}) InList {
+	v := reflect.ValueOf(values)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return InList{values}
+	}
+	list := make(InList, v.Len())
+	for i := range list {
+		list[i] = v.Index(i).Interface()
+	}
+	return list
+}
+
+// ExpandIn expands the InList arguments of a hand-written query into a
+// placeholder for each value, as QueryRow, QueryAll, and Exec do, and
+// returns the new query along with the flattened arguments. Queries
+// without InList arguments are returned as they are.
+func (d *Database) ExpandIn(query string, args ...interface{}) (string, []interface{}) {
+	return d.expandIn(query, args)
+}
+
+// ExpandIn using the Default Database type
+func ExpandIn(query string, args ...interface{}) (string, []interface{}) {
+	return Default.ExpandIn(query, args...)
+}
+
+// expandIn expands InList arguments into a placeholder for each value.
+// The placeholders in query may be ?, or the numbered placeholders of the
+// database, which are renumbered to match the flattened arguments.
+func (d *Database) expandIn(query string, args []interface{}) (string, []interface{}) {
+	hasList := false
+	for _, arg := range args {
+		if _, ok := arg.(InList); ok {
+			hasList = true
+			break
+		}
+	}
+	if !hasList {
+		return query, args
+	}
+
+	// work out where each argument starts once the lists are flattened
+	var flat []interface{}
+	starts := make([]int, len(args))
+	for i, arg := range args {
+		starts[i] = len(flat) + 1
+		if list, ok := arg.(InList); ok {
+			flat = append(flat, list...)
+		} else {
+			flat = append(flat, arg)
+		}
+	}
+	expand := func(i int, numbered bool) string {
+		list, ok := args[i].(InList)
+		if !ok {
+			list = InList{args[i]}
+		}
+		if len(list) == 0 {
+			return "NULL"
+		}
+		parts := make([]string, len(list))
+		for j := range parts {
+			parts[j] = "?"
+			if numbered {
+				parts[j] = d.placeholder(starts[i] + j)
+			}
+		}
+		return strings.Join(parts, ",")
+	}
+
+	// numbered placeholders are recognized by the prefix before the number
+	prefix := d.dialect().PlaceholderPrefix()
+
+	var b strings.Builder
+	next := 0
+	brackets := d.dialect().BracketQuotes()
+	for i := 0; i < len(query); {
+		end, code := nextToken(query, i, brackets)
+		if code && strings.HasPrefix(query[i:], "??") {
+			// an escaped question mark, left for Rebind
+			b.WriteString("??")
+			i += 2
+			continue
+		}
+		if code && query[i] == '?' && next < len(args) {
+			b.WriteString(expand(next, false))
+			next++
+			i = end
+			continue
+		}
+		if code && prefix != "" && strings.HasPrefix(query[i:], prefix) {
+			digits := len(prefix)
+			for i+digits < len(query) && '0' <= query[i+digits] && query[i+digits] <= '9' {
+				digits++
+			}
+			if n, err := strconv.Atoi(query[i+len(prefix) : i+digits]); err == nil && n >= 1 && n <= len(args) {
+				b.WriteString(expand(n-1, true))
+				i += digits
+				continue
+			}
+		}
+		b.WriteString(query[i:end])
+		i = end
+	}
+	return b.String(), flat
+}
@@ -0,0 +1,76 @@
+package meddler
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExpandIn(t *testing.T) {
+	tests := []struct {
+		d        *Database
+		query    string
+		args     []interface{}
+		expected string
+		flat     []interface{}
+	}{
+		{MySQL, "SELECT * FROM t WHERE a = ? AND id IN (?)", []interface{}{1, In([]int64{2, 3, 4})}, "SELECT * FROM t WHERE a = ? AND id IN (?,?,?)", []interface{}{1, int64(2), int64(3), int64(4)}},
+		{MySQL, "SELECT * FROM t WHERE id IN (?) AND b = '?'", []interface{}{In([]string{})}, "SELECT * FROM t WHERE id IN (NULL) AND b = '?'", nil},
+		{PostgreSQL, "SELECT * FROM t WHERE a = $1 AND id IN ($2) AND b = $3 OR c = $1", []interface{}{1, In([2]int{2, 3}), 4}, "SELECT * FROM t WHERE a = $1 AND id IN ($2,$3) AND b = $4 OR c = $1", []interface{}{1, 2, 3, 4}},
+		{PostgreSQL, "SELECT * FROM t WHERE id IN ($1) AND b = $2", []interface{}{In([]int{}), 4}, "SELECT * FROM t WHERE id IN (NULL) AND b = $1", []interface{}{4}},
+		{SQLServer, "SELECT * FROM t WHERE id IN (@p1) AND [@p2] = @p2", []interface{}{In([]int{7, 8}), 9}, "SELECT * FROM t WHERE id IN (@p1,@p2) AND [@p2] = @p3", []interface{}{7, 8, 9}},
+		{MySQL, "SELECT * FROM t WHERE data = ?", []interface{}{[]byte("raw")}, "SELECT * FROM t WHERE data = ?", []interface{}{[]byte("raw")}},
+		{PostgreSQL, "SELECT * FROM t WHERE data ?? ? AND id IN (?)", []interface{}{"k", In([]int{1, 2})}, "SELECT * FROM t WHERE data ?? ? AND id IN (?,?)", []interface{}{"k", 1, 2}},
+	}
+	for i, test := range tests {
+		q, args := test.d.expandIn(test.query, test.args)
+		if q != test.expected {
+			t.Errorf("%d: expected %q, found %q", i, test.expected, q)
+		}
+		if !reflect.DeepEqual(args, test.flat) {
+			t.Errorf("%d: expected args %v, found %v", i, test.flat, args)
+		}
+	}
+
+	if list := In(5); !reflect.DeepEqual(list, InList{5}) {
+		t.Errorf("In of a single value: expected a list of one, found %v", list)
+	}
+}
+
+func TestInQueries(t *testing.T) {
+	once.Do(setup)
+
+	var ids []int64
+	for _, name := range []string{"a", "b", "c"} {
+		elt := &Tag{Name: name}
+		if err := Insert(db, "tag", elt); err != nil {
+			t.Fatalf("Insert error: %v", err)
+		}
+		ids = append(ids, elt.ID)
+	}
+
+	var all []*Tag
+	if err := QueryAll(db, &all, "select * from tag where id in (?) and name <> ? order by id", In(ids[1:]), "x"); err != nil {
+		t.Fatalf("QueryAll error: %v", err)
+	}
+	if len(all) != 2 || all[0].Name != "b" || all[1].Name != "c" {
+		t.Errorf("QueryAll: expected b and c, found %v", all)
+	}
+
+	all = nil
+	if err := QueryAll(db, &all, "select * from tag where id in (?)", In([]int64{})); err != nil {
+		t.Fatalf("QueryAll with empty list error: %v", err)
+	}
+	if len(all) != 0 {
+		t.Errorf("QueryAll with empty list: expected no rows, found %d", len(all))
+	}
+
+	if _, err := NamedExec(db, "delete from tag where name in (:names)", map[string]interface{}{"names": In([]string{"a", "b"})}); err != nil {
+		t.Fatalf("NamedExec error: %v", err)
+	}
+	var count int
+	db.QueryRow("select count(*) from tag").Scan(&count)
+	if count != 1 {
+		t.Errorf("NamedExec: expected 1 row left, found %d", count)
+	}
+	db.Exec("delete from tag")
+}
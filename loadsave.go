@@ -1,132 +1,386 @@
 package meddler
 
 import (
+	"context"
 	"database/sql"
+	"database/sql/driver"
 	"fmt"
+	"reflect"
 	"strings"
+	"time"
 )
 
-type dbErr struct {
-	msg string
-	err error
+// DB is a generic database interface, matching both *sql.Db and *sql.Tx
+type DB interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
 }
 
-func (err *dbErr) Error() string {
-	return fmt.Sprintf("%s: %v", err.msg, err.err)
+// DBContext is a generic database interface with context support,
+// matching *sql.DB, *sql.Tx, and *sql.Conn
+type DBContext interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
 }
 
-// DriverErr returns the original error as returned by the database driver
-// if the error comes from the driver, with the second value set to true.
-// Otherwise, it returns err itself with false as second value.
-func DriverErr(err error) (error, bool) {
-	if dbe, ok := err.(*dbErr); ok {
-		return dbe.err, true
+// withContext converts a DB into a DBContext. *sql.DB and *sql.Tx already
+// implement both interfaces and are returned unchanged; anything else is
+// wrapped and the context is ignored.
+func withContext(db DB) DBContext {
+	if dbc, ok := db.(DBContext); ok {
+		return dbc
 	}
-	return err, false
+	return noContextDB{db}
 }
 
-// DB is a generic database interface, matching both *sql.Db and *sql.Tx
-type DB interface {
-	Exec(query string, args ...interface{}) (sql.Result, error)
-	Query(query string, args ...interface{}) (*sql.Rows, error)
-	QueryRow(query string, args ...interface{}) *sql.Row
+type noContextDB struct {
+	db DB
+}
+
+func (n noContextDB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return n.db.Exec(query, args...)
+}
+
+func (n noContextDB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return n.db.Query(query, args...)
+}
+
+func (n noContextDB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return n.db.QueryRow(query, args...)
 }
 
 // Load loads a record using a query for the primary key field.
-// Returns sql.ErrNoRows if not found.
+// Returns sql.ErrNoRows if not found, or if the row has been soft deleted.
 func (d *Database) Load(db DB, table string, dst interface{}, pk int64) error {
+	return d.LoadContext(context.Background(), withContext(db), table, dst, pk)
+}
+
+// LoadContext is the context-aware version of Load.
+func (d *Database) LoadContext(ctx context.Context, db DBContext, table string, dst interface{}, pk int64) error {
+	return d.LoadByKeyContext(ctx, db, table, dst, pk)
+}
+
+// Load using the Default Database type
+func Load(db DB, table string, dst interface{}, pk int64) error {
+	return Default.Load(db, table, dst, pk)
+}
+
+// LoadContext using the Default Database type
+func LoadContext(ctx context.Context, db DBContext, table string, dst interface{}, pk int64) error {
+	return Default.LoadContext(ctx, db, table, dst, pk)
+}
+
+// LoadByKey loads a record using a query for all of the primary key fields.
+// The key values must be given in the same order as the primary key fields
+// appear in the struct. Returns sql.ErrNoRows if not found, or if the row
+// has been soft deleted.
+func (d *Database) LoadByKey(db DB, table string, dst interface{}, keys ...interface{}) error {
+	return d.LoadByKeyContext(context.Background(), withContext(db), table, dst, keys...)
+}
+
+// LoadByKeyContext is the context-aware version of LoadByKey.
+func (d *Database) LoadByKeyContext(ctx context.Context, db DBContext, table string, dst interface{}, keys ...interface{}) error {
+	if err := d.checkIdentifiers("meddler.Load", table, dst); err != nil {
+		return err
+	}
 	columns, err := d.ColumnsQuoted(dst, true)
 	if err != nil {
 		return err
 	}
 
-	// make sure we have a primary key field
-	pkName, _, err := d.PrimaryKey(dst)
+	// make sure we have a primary key field for each key
+	pkNames, _, err := d.PrimaryKeys(dst)
 	if err != nil {
 		return err
 	}
-	if pkName == "" {
-		return fmt.Errorf("meddler.Load: no primary key field found")
+	if len(pkNames) == 0 {
+		return fmt.Errorf("meddler.Load: %w", ErrNoPrimaryKey)
+	}
+	if len(keys) != len(pkNames) {
+		return fmt.Errorf("meddler.Load: %w: struct has %d primary key fields, but %d key values were given", ErrKeyCount, len(pkNames), len(keys))
 	}
 
 	// run the query
-	q := fmt.Sprintf("SELECT %s FROM %s WHERE %s = %s", columns, d.quoted(table), d.quoted(pkName), d.Placeholder)
+	q := fmt.Sprintf("SELECT %s FROM %s WHERE %s", columns, d.QuoteTable(table), d.whereKeys(pkNames, 1))
+
+	args := make([]interface{}, len(keys))
+	for i, key := range keys {
+		args[i] = byteArrayArg(key)
+	}
 
-	rows, err := db.Query(q, pk)
+	// skip rows that have been soft deleted
+	field, fieldType, err := softDeleteField(dst)
+	if err != nil {
+		return err
+	}
+	if field != nil {
+		filter, filterArgs, err := d.softDeleteFilter(ctx, field, fieldType, false, d.placeholder(len(args)+1))
+		if err != nil {
+			return err
+		}
+		q += " AND " + filter
+		args = append(args, filterArgs...)
+	}
+	rows, err := db.QueryContext(ctx, q, args...)
 	if err != nil {
-		return &dbErr{msg: "meddler.Load: DB error in Query", err: err}
+		return &QueryError{Op: "meddler.Load", Table: table, SQL: q, Err: err}
 	}
 
 	// scan the row
-	return d.ScanRow(rows, dst)
+	if err := d.ScanRowContext(ctx, rows, dst); err != nil {
+		return err
+	}
+	return afterLoad(ctx, db, dst)
 }
 
-// Load using the Default Database type
-func Load(db DB, table string, dst interface{}, pk int64) error {
-	return Default.Load(db, table, dst, pk)
+// LoadByKey using the Default Database type
+func LoadByKey(db DB, table string, dst interface{}, keys ...interface{}) error {
+	return Default.LoadByKey(db, table, dst, keys...)
+}
+
+// LoadByKeyContext using the Default Database type
+func LoadByKeyContext(ctx context.Context, db DBContext, table string, dst interface{}, keys ...interface{}) error {
+	return Default.LoadByKeyContext(ctx, db, table, dst, keys...)
+}
+
+// whereKeys forms the condition matching a row by its primary key columns,
+// e.g. `a`=? AND `b`=?, with placeholders numbered starting at first.
+func (d *Database) whereKeys(pkNames []string, first int) string {
+	var pairs []string
+	for i, name := range pkNames {
+		pairs = append(pairs, fmt.Sprintf("%s=%s", d.quoted(name), d.placeholder(first+i)))
+	}
+	return strings.Join(pairs, " AND ")
+}
+
+// KeyGenerator can be implemented by structs with a primary key that is
+// not an integer. When such a struct is inserted with a zero primary key,
+// GenerateKey is called and the value it returns is stored in the primary
+// key field before the record is written. It is only called once the record
+// has passed validation, and the key is cleared again if the insert fails.
+type KeyGenerator interface {
+	GenerateKey() (interface{}, error)
 }
 
 // Insert performs an INSERT query for the given record.
-// If the record has a primary key flagged, it must be zero, and it
+// If the record has an integer primary key flagged, it must be zero, and it
 // will be set to the newly-allocated primary key value from the database
-// as returned by LastInsertId.
+// as returned by LastInsertId, or to the next value of its sequence if it
+// has the sequence option. Other primary keys are inserted like any
+// other field if they are set, and generated by KeyGenerator if they are
+// not. The fields of a composite primary key are always inserted.
+// Fields marked as created or updated are set to the current time.
 func (d *Database) Insert(db DB, table string, src interface{}) error {
-	pkName, pkValue, err := d.PrimaryKey(src)
+	return d.InsertContext(context.Background(), withContext(db), table, src)
+}
+
+// InsertContext is the context-aware version of Insert.
+func (d *Database) InsertContext(ctx context.Context, db DBContext, table string, src interface{}) (err error) {
+	if err := d.checkIdentifiers("meddler.Insert", table, src); err != nil {
+		return err
+	}
+	if err := beforeInsert(ctx, db, src); err != nil {
+		return err
+	}
+	pkNames, _, err := d.PrimaryKeys(src)
+	if err != nil {
+		return err
+	}
+
+	// an insert that fails before the row is written leaves the fields
+	// meddler sets as they were
+	backup, err := backupFields(src, insertSets)
 	if err != nil {
 		return err
 	}
-	if pkName != "" && pkValue != 0 {
-		return fmt.Errorf("meddler.Insert: primary key must be zero")
+	inserted := false
+	defer func() {
+		if err != nil && !inserted {
+			backup.restore()
+		}
+	}()
+
+	if err := initVersion(src); err != nil {
+		return err
+	}
+	if _, err := d.touch(src, true); err != nil {
+		return err
+	}
+	if err := d.validateInsert(src); err != nil {
+		return err
+	}
+
+	// pkName is only set if the database must supply the key,
+	// otherwise the key is written along with the other columns
+	pkName, includePk := "", len(pkNames) > 1
+	if len(pkNames) == 1 {
+		if pkName, err = d.insertKey(ctx, db, src); err != nil {
+			return err
+		}
+		includePk = pkName == ""
 	}
 
 	// gather the query parts
-	namesPart, err := d.ColumnsQuoted(src, false)
+	namesPart, err := d.ColumnsQuoted(src, includePk)
 	if err != nil {
 		return err
 	}
-	valuesPart, err := d.PlaceholdersString(src, false)
+	valuesPart, err := d.PlaceholdersString(src, includePk)
 	if err != nil {
 		return err
 	}
-	values, err := d.Values(src, false)
+	values, err := d.ValuesContext(ctx, src, includePk)
 	if err != nil {
 		return err
 	}
 
 	// run the query
-	q := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", d.quoted(table), namesPart, valuesPart)
-	if d.UseReturningToGetID && pkName != "" {
-		q += " RETURNING " + d.quoted(pkName)
-		var newPk int64
-		err := db.QueryRow(q, values...).Scan(&newPk)
+	q := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", d.QuoteTable(table), namesPart, valuesPart)
+	if output, returning, ok := d.dialect().InsertReturning(d.quoted(pkName)); ok && pkName != "" {
+		q = fmt.Sprintf("INSERT INTO %s (%s)%s VALUES (%s)%s", d.QuoteTable(table), namesPart, output, valuesPart, returning)
+		targets, err := d.TargetsContext(ctx, src, []string{pkName})
 		if err != nil {
-			return &dbErr{msg: "meddler.Insert: DB error in QueryRow", err: err}
+			return err
 		}
-		if err = d.SetPrimaryKey(src, newPk); err != nil {
-			return fmt.Errorf("meddler.Insert: Error saving updated pk: %v", err)
+		if err := db.QueryRowContext(ctx, q, values...).Scan(targets...); err != nil {
+			return &QueryError{Op: "meddler.Insert", Table: table, SQL: q, Err: err}
+		}
+		if err = d.WriteTargetsContext(ctx, src, []string{pkName}, targets); err != nil {
+			return fmt.Errorf("meddler.Insert: Error saving updated pk: %w", err)
+		}
+	} else if _, ok := d.dialect().(ReturningIntoDialect); ok && pkName != "" {
+		if err := d.insertReturningInto(ctx, db, "meddler.Insert", table, q, values, src, pkName); err != nil {
+			return err
 		}
 	} else if pkName != "" {
-		result, err := db.Exec(q, values...)
+		result, err := db.ExecContext(ctx, q, values...)
 		if err != nil {
-			return &dbErr{msg: "meddler.Insert: DB error in Exec", err: err}
+			return &QueryError{Op: "meddler.Insert", Table: table, SQL: q, Err: err}
 		}
 
 		// save the new primary key
 		newPk, err := result.LastInsertId()
 		if err != nil {
-			return &dbErr{msg: "meddler.Insert: DB error getting new primary key value", err: err}
+			return &QueryError{Op: "meddler.Insert", Table: table, SQL: q, Err: err}
 		}
 		if err = d.SetPrimaryKey(src, newPk); err != nil {
-			return fmt.Errorf("meddler.Insert: Error saving updated pk: %v", err)
+			return fmt.Errorf("meddler.Insert: Error saving updated pk: %w", err)
 		}
 	} else {
 		// no primary key, so no need to lookup new value
-		_, err := db.Exec(q, values...)
+		_, err := db.ExecContext(ctx, q, values...)
+		if err != nil {
+			return &QueryError{Op: "meddler.Insert", Table: table, SQL: q, Err: err}
+		}
+	}
+	inserted = true
+
+	if err := d.snapshotAll(ctx, src); err != nil {
+		return err
+	}
+	return afterInsert(ctx, db, src)
+}
+
+// validateInsert validates the columns of src that an insert writes. It
+// runs before insertKey, so nothing is generated or drawn from a sequence
+// for a record that is not valid. A single primary key that is still zero
+// is not checked, since it is about to be filled in.
+func (d *Database) validateInsert(src interface{}) error {
+	pkNames, pkValues, err := d.PrimaryKeys(src)
+	if err != nil {
+		return err
+	}
+	includePk := len(pkNames) > 1 || len(pkNames) == 1 && !isZeroKey(reflect.ValueOf(pkValues[0]))
+	names, err := d.Columns(src, includePk)
+	if err != nil {
+		return err
+	}
+	return validate(src, names)
+}
+
+// insertKey checks a single primary key field before an insert, and
+// returns its name if the database is expected to supply a new key.
+// Integer keys must be zero so the database can allocate them, or so the
+// next value of their sequence can be filled in. Other keys are filled in
+// by KeyGenerator if they are zero. Keys that are filled in are inserted
+// along with the other columns, in which case the name returned is empty.
+func (d *Database) insertKey(ctx context.Context, db DBContext, src interface{}) (string, error) {
+	pkName, pkValue, err := d.PrimaryKeyValue(src)
+	if err != nil {
+		return "", err
+	}
+	key := reflect.ValueOf(pkValue)
+
+	if isIntegerKey(key.Type()) {
+		if !isZeroKey(key) {
+			return "", fmt.Errorf("meddler.Insert: %w", ErrPrimaryKeyNotZero)
+		}
+		data, err := getFields(reflect.TypeOf(src))
+		if err != nil {
+			return "", err
+		}
+		if sequence := data.fields[pkName].sequence; sequence != "" {
+			return "", d.nextValue(ctx, db, src, sequence)
+		}
+		return pkName, nil
+	}
+	if !isZeroKey(key) {
+		return "", nil
+	}
+
+	if gen, ok := src.(KeyGenerator); ok {
+		newKey, err := gen.GenerateKey()
 		if err != nil {
-			return &dbErr{msg: "meddler.Insert: DB error in Exec", err: err}
+			return "", fmt.Errorf("meddler.Insert: Error generating pk: %w", err)
+		}
+		if err := d.SetPrimaryKeyValue(src, newKey); err != nil {
+			return "", fmt.Errorf("meddler.Insert: Error saving generated pk: %w", err)
 		}
+		return "", nil
 	}
+	if !d.returnsKeys() {
+		return "", fmt.Errorf("meddler.Insert: %w: primary key %s is zero, and a %v key cannot be read back with LastInsertId", ErrNotSupported, pkName, key.Type())
+	}
+	return pkName, nil
+}
+
+// nextValue sets the primary key of src to the next value of a sequence.
+// A value that is drawn cannot be given back, so src must already have
+// been validated.
+func (d *Database) nextValue(ctx context.Context, db DBContext, src interface{}, sequence string) error {
+	seq, ok := d.dialect().(SequenceDialect)
+	if !ok {
+		return fmt.Errorf("meddler.Insert: %w: primary key uses sequence %s", ErrNotSupported, sequence)
+	}
+	q := seq.NextValue(sequence)
+	var key int64
+	if err := db.QueryRowContext(ctx, q).Scan(&key); err != nil {
+		return &QueryError{Op: "meddler.Insert", SQL: q, Err: err}
+	}
+	if err := d.SetPrimaryKey(src, key); err != nil {
+		return fmt.Errorf("meddler.Insert: Error saving pk from sequence: %w", err)
+	}
+	return nil
+}
 
+// insertReturningInto runs an INSERT query that stores the new key of src
+// in an output parameter, for dialects that implement ReturningIntoDialect.
+func (d *Database) insertReturningInto(ctx context.Context, db DBContext, op, table, q string, values []interface{}, src interface{}, pkName string) error {
+	into := d.dialect().(ReturningIntoDialect)
+	q += into.InsertReturningInto(d.quoted(pkName), len(values)+1)
+	targets, err := d.TargetsContext(ctx, src, []string{pkName})
+	if err != nil {
+		return err
+	}
+	args := append(values[:len(values):len(values)], sql.Out{Dest: targets[0]})
+	if _, err := db.ExecContext(ctx, q, args...); err != nil {
+		return &QueryError{Op: op, Table: table, SQL: q, Err: err}
+	}
+	if err := d.WriteTargetsContext(ctx, src, []string{pkName}, targets); err != nil {
+		return fmt.Errorf("%s: Error saving updated pk: %w", op, err)
+	}
 	return nil
 }
 
@@ -135,113 +389,1385 @@ func Insert(db DB, table string, src interface{}) error {
 	return Default.Insert(db, table, src)
 }
 
-// Update performs and UPDATE query for the given record.
-// The record must have an integer primary key field that is non-zero,
-// and it will be used to select the database row that gets updated.
-func (d *Database) Update(db DB, table string, src interface{}) error {
-	// gather the query parts
-	names, err := d.Columns(src, false)
-	if err != nil {
-		return err
+// InsertContext using the Default Database type
+func InsertContext(ctx context.Context, db DBContext, table string, src interface{}) error {
+	return Default.InsertContext(ctx, db, table, src)
+}
+
+// InsertAll inserts every element of src, which must be a slice of structs
+// or of pointers to structs, using multi-row INSERT queries. Each query
+// stays under the MaxPlaceholders and MaxInsertRows limits of the
+// database, so large slices are split into several batches. Primary keys
+// are handled as in Insert, and keys allocated by the database are
+// written back into the elements.
+//
+// Rows that need a key from the database are only batched if their keys
+// can be matched to them. PostgreSQL draws the keys from the sequence of
+// the key column first and inserts them with the rows; MySQL and SQLite
+// use the LastInsertID range described by BulkInsertID. RETURNING and
+// OUTPUT do not promise to give keys in the order of the rows, so with
+// SQL Server, Oracle, MySQL with BulkInsertIDNone (the default), and
+// PostgreSQL keys that do not come from a sequence, rows that need a key
+// are inserted one per statement.
+func (d *Database) InsertAll(db DB, table string, src interface{}) error {
+	return d.InsertAllContext(context.Background(), withContext(db), table, src)
+}
+
+// InsertAllContext is the context-aware version of InsertAll.
+func (d *Database) InsertAllContext(ctx context.Context, db DBContext, table string, src interface{}) (err error) {
+	// gather pointers to the elements
+	sliceVal := reflect.ValueOf(src)
+	if sliceVal.Kind() == reflect.Ptr && !sliceVal.IsNil() {
+		sliceVal = sliceVal.Elem()
 	}
-	placeholders, err := d.Placeholders(src, false)
-	if err != nil {
+	if sliceVal.Kind() != reflect.Slice {
+		return &ConfigError{Type: reflect.TypeOf(src), Msg: "meddler.InsertAll called with non-slice"}
+	}
+	if sliceVal.Len() == 0 {
+		return nil
+	}
+	var elts []interface{}
+	for i := 0; i < sliceVal.Len(); i++ {
+		eltVal := sliceVal.Index(i)
+		if eltVal.Kind() == reflect.Interface {
+			eltVal = eltVal.Elem()
+		}
+		if eltVal.Kind() == reflect.Ptr {
+			if eltVal.IsNil() {
+				return &ConfigError{Type: reflect.TypeOf(src), Msg: fmt.Sprintf("meddler.InsertAll called with nil element %d", i)}
+			}
+		} else if eltVal.CanAddr() {
+			eltVal = eltVal.Addr()
+		} else {
+			return &ConfigError{Type: eltVal.Type(), Msg: fmt.Sprintf("meddler.InsertAll called with non-pointer element %d", i)}
+		}
+		if i > 0 && eltVal.Type() != reflect.TypeOf(elts[0]) {
+			return &ConfigError{Type: eltVal.Type(), Msg: fmt.Sprintf("meddler.InsertAll called with elements of different types, %v and %T", eltVal.Type(), elts[0])}
+		}
+		elts = append(elts, eltVal.Interface())
+	}
+	if err := d.checkIdentifiers("meddler.InsertAll", table, elts[0]); err != nil {
 		return err
 	}
-	values, err := d.Values(src, false)
+
+	// a failed insert leaves the fields meddler sets as they were in
+	// every row that was not inserted
+	var backups []*fieldBackup
+	inserted := 0
+	defer func() {
+		if err != nil {
+			for _, backup := range backups[inserted:] {
+				backup.restore()
+			}
+		}
+	}()
+
+	for _, elt := range elts {
+		if err := beforeInsert(ctx, db, elt); err != nil {
+			return err
+		}
+		backup, err := backupFields(elt, insertSets)
+		if err != nil {
+			return err
+		}
+		backups = append(backups, backup)
+		if err := initVersion(elt); err != nil {
+			return err
+		}
+		if _, err := d.touch(elt, true); err != nil {
+			return err
+		}
+		if err := d.validateInsert(elt); err != nil {
+			return err
+		}
+	}
+
+	// check the primary keys; every row must agree on
+	// whether the key is written or supplied by the database
+	pkNames, _, err := d.PrimaryKeys(elts[0])
 	if err != nil {
 		return err
 	}
+	pkName, includePk := "", len(pkNames) > 1
+	if len(pkNames) == 1 {
+		for i, elt := range elts {
+			name, err := d.insertKey(ctx, db, elt)
+			if err != nil {
+				return err
+			}
+			if i > 0 && name != pkName {
+				return fmt.Errorf("meddler.InsertAll: %w: the key is set for some elements but not others", ErrPrimaryKeyNotZero)
+			}
+			pkName = name
+		}
+		includePk = pkName == ""
+	}
 
-	// form the column=placeholder pairs
-	var pairs []string
-	for i := 0; i < len(names) && i < len(placeholders); i++ {
-		pair := fmt.Sprintf("%s=%s", d.quoted(names[i]), placeholders[i])
-		pairs = append(pairs, pair)
+	// keys drawn ahead of time are inserted like any other column
+	override := ""
+	if pkName != "" {
+		allocated, clause, err := d.allocateKeys(ctx, db, table, elts, pkName)
+		if err != nil {
+			return err
+		}
+		if allocated {
+			pkName, includePk, override = "", true, clause
+		}
 	}
 
-	pkName, pkValue, err := d.PrimaryKey(src)
+	names, err := d.Columns(elts[0], includePk)
 	if err != nil {
 		return err
 	}
-	if pkName == "" {
-		return fmt.Errorf("meddler.Update: no primary key field")
+
+	// work out how many rows fit in a single query
+	batchSize := len(elts)
+	if d.MaxPlaceholders > 0 && len(names) > 0 && d.MaxPlaceholders/len(names) < batchSize {
+		batchSize = d.MaxPlaceholders / len(names)
 	}
-	if pkValue < 1 {
-		return fmt.Errorf("meddler.Update: primary key must be an integer > 0")
+	if d.MaxInsertRows > 0 && d.MaxInsertRows < batchSize {
+		batchSize = d.MaxInsertRows
+	}
+	if pkName != "" && d.BulkInsertID == BulkInsertIDNone {
+		batchSize = 1
+	}
+	if _, ok := d.dialect().(ReturningIntoDialect); ok {
+		// output parameters only hold a single key
+		batchSize = 1
+	}
+	if batchSize < 1 {
+		return &ConfigError{Type: reflect.TypeOf(elts[0]), Msg: fmt.Sprintf("has %d columns, more than the limit of %d placeholders", len(names), d.MaxPlaceholders)}
 	}
-	ph := d.placeholder(len(placeholders) + 1)
-
-	// run the query
-	q := fmt.Sprintf("UPDATE %s SET %s WHERE %s=%s", d.quoted(table),
-		strings.Join(pairs, ","),
-		d.quoted(pkName), ph)
-	values = append(values, pkValue)
 
-	if _, err := db.Exec(q, values...); err != nil {
-		return &dbErr{msg: "meddler.Update: DB error in Exec", err: err}
+	for start := 0; start < len(elts); start += batchSize {
+		end := start + batchSize
+		if end > len(elts) {
+			end = len(elts)
+		}
+		if err := d.insertBatch(ctx, db, table, elts[start:end], names, includePk, pkName, override); err != nil {
+			return err
+		}
+		inserted = end
+		for _, elt := range elts[start:end] {
+			if err := afterInsert(ctx, db, elt); err != nil {
+				return err
+			}
+		}
 	}
 
 	return nil
 }
 
-// Update using the Default Database type
-func Update(db DB, table string, src interface{}) error {
-	return Default.Update(db, table, src)
-}
+// allocateKeys fills in the integer primary keys of elts with values drawn
+// from the sequence of the key column, for dialects that implement
+// KeyBlockDialect, so rows that need a key can still be inserted together.
+// It reports whether the keys were drawn, leaving elts unchanged if not,
+// along with the clause that makes the database accept them.
+func (d *Database) allocateKeys(ctx context.Context, db DBContext, table string, elts []interface{}, pkName string) (bool, string, error) {
+	block, ok := d.dialect().(KeyBlockDialect)
+	if !ok {
+		return false, "", nil
+	}
+	_, pk, err := d.PrimaryKeyValue(elts[0])
+	if err != nil {
+		return false, "", err
+	}
+	if !isIntegerKey(reflect.TypeOf(pk)) {
+		return false, "", nil
+	}
 
-// Save performs an INSERT or an UPDATE, depending on whether or not
-// a primary keys exists and is non-zero.
-func (d *Database) Save(db DB, table string, src interface{}) error {
-	pkName, pkValue, err := d.PrimaryKey(src)
+	q, override := block.NextKeys(table, pkName, len(elts))
+	rows, err := db.QueryContext(ctx, q)
 	if err != nil {
-		return err
+		return false, "", &QueryError{Op: "meddler.InsertAll", Table: table, SQL: q, Err: err}
+	}
+	defer rows.Close()
+	var keys []int64
+	for rows.Next() {
+		var key sql.NullInt64
+		if err := rows.Scan(&key); err != nil {
+			return false, "", &QueryError{Op: "meddler.InsertAll", Table: table, SQL: q, Err: err}
+		}
+		if !key.Valid {
+			// the column has no sequence
+			return false, "", nil
+		}
+		keys = append(keys, key.Int64)
+	}
+	if err := rows.Err(); err != nil {
+		return false, "", &QueryError{Op: "meddler.InsertAll", Table: table, SQL: q, Err: err}
 	}
-	if pkName != "" && pkValue != 0 {
-		return d.Update(db, table, src)
+	if len(keys) != len(elts) {
+		return false, "", fmt.Errorf("meddler.InsertAll: %w: expected %d new primary key values, found %d", ErrKeyCount, len(elts), len(keys))
 	}
 
-	return d.Insert(db, table, src)
+	for i, elt := range elts {
+		if err := d.SetPrimaryKey(elt, keys[i]); err != nil {
+			return false, "", fmt.Errorf("meddler.InsertAll: Error saving pk from sequence: %w", err)
+		}
+	}
+	return true, override, nil
 }
 
-// Save using the Default Database type
-func Save(db DB, table string, src interface{}) error {
-	return Default.Save(db, table, src)
-}
+// insertBatch inserts a group of rows with a single query. If pkName is
+// set, the keys allocated by the database are stored in the elements.
+// override goes between the column list and VALUES.
+func (d *Database) insertBatch(ctx context.Context, db DBContext, table string, elts []interface{}, names []string, includePk bool, pkName, override string) error {
+	// gather the query parts, numbering placeholders across all rows
+	var quoted []string
+	for _, name := range names {
+		quoted = append(quoted, d.quoted(name))
+	}
+	var rowParts []string
+	var values []interface{}
+	for _, elt := range elts {
+		eltValues, err := d.ValuesContext(ctx, elt, includePk)
+		if err != nil {
+			return err
+		}
+		var placeholders []string
+		for range eltValues {
+			placeholders = append(placeholders, d.placeholder(len(values)+len(placeholders)+1))
+		}
+		rowParts = append(rowParts, "("+strings.Join(placeholders, ",")+")")
+		values = append(values, eltValues...)
+	}
 
-// QueryRow performs the given query with the given arguments, scanning a
-// single row of results into dst. Returns sql.ErrNoRows if there was no
-// result row.
-func (d *Database) QueryRow(db DB, dst interface{}, query string, args ...interface{}) error {
-	// perform the query
-	rows, err := db.Query(query, args...)
+	// run the query
+	q := fmt.Sprintf("INSERT INTO %s (%s)%s VALUES %s", d.QuoteTable(table), strings.Join(quoted, ","), override, strings.Join(rowParts, ","))
+	if output, returning, ok := d.dialect().InsertReturning(d.quoted(pkName)); ok && pkName != "" {
+		q = fmt.Sprintf("INSERT INTO %s (%s)%s VALUES %s%s", d.QuoteTable(table), strings.Join(quoted, ","), output, strings.Join(rowParts, ","), returning)
+		rows, err := db.QueryContext(ctx, q, values...)
+		if err != nil {
+			return &QueryError{Op: "meddler.InsertAll", Table: table, SQL: q, Err: err}
+		}
+		defer rows.Close()
+
+		// batches that read keys back this way hold a single row,
+		// since the order of the returned rows is not defined
+		for _, elt := range elts {
+			if !rows.Next() {
+				if err := rows.Err(); err != nil {
+					return &QueryError{Op: "meddler.InsertAll", Table: table, SQL: q, Err: err}
+				}
+				return fmt.Errorf("meddler.InsertAll: %w: expected %d new primary key values", ErrKeyCount, len(elts))
+			}
+			targets, err := d.TargetsContext(ctx, elt, []string{pkName})
+			if err != nil {
+				return err
+			}
+			if err := rows.Scan(targets...); err != nil {
+				return &QueryError{Op: "meddler.InsertAll", Table: table, SQL: q, Err: err}
+			}
+			if err := d.WriteTargetsContext(ctx, elt, []string{pkName}, targets); err != nil {
+				return fmt.Errorf("meddler.InsertAll: Error saving updated pk: %w", err)
+			}
+		}
+		if err := rows.Close(); err != nil {
+			return &QueryError{Op: "meddler.InsertAll", Table: table, SQL: q, Err: err}
+		}
+		return d.snapshotBatch(ctx, elts)
+	}
+
+	if _, ok := d.dialect().(ReturningIntoDialect); ok && pkName != "" {
+		// batches hold a single row
+		if err := d.insertReturningInto(ctx, db, "meddler.InsertAll", table, q, values, elts[0], pkName); err != nil {
+			return err
+		}
+		return d.snapshotBatch(ctx, elts)
+	}
+
+	result, err := db.ExecContext(ctx, q, values...)
 	if err != nil {
-		return err
+		return &QueryError{Op: "meddler.InsertAll", Table: table, SQL: q, Err: err}
+	}
+	if pkName == "" {
+		return d.snapshotBatch(ctx, elts)
 	}
 
-	// gather the result
-	return d.ScanRow(rows, dst)
+	// work out the new keys from the range that was allocated
+	lastPk, err := result.LastInsertId()
+	if err != nil {
+		return &QueryError{Op: "meddler.InsertAll", Table: table, SQL: q, Err: err}
+	}
+	firstPk := lastPk
+	if d.BulkInsertID == BulkInsertIDLast {
+		firstPk = lastPk - int64(len(elts)-1)
+	}
+	for i, elt := range elts {
+		if err := d.SetPrimaryKey(elt, firstPk+int64(i)); err != nil {
+			return fmt.Errorf("meddler.InsertAll: Error saving updated pk: %w", err)
+		}
+	}
+
+	return d.snapshotBatch(ctx, elts)
 }
 
-// QueryRow using the Default Database type
-func QueryRow(db DB, dst interface{}, query string, args ...interface{}) error {
-	return Default.QueryRow(db, dst, query, args...)
+// snapshotBatch records the values of inserted rows for change tracking.
+func (d *Database) snapshotBatch(ctx context.Context, elts []interface{}) error {
+	for _, elt := range elts {
+		if err := d.snapshotAll(ctx, elt); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-// QueryAll performs the given query with the given arguments, scanning
-// all results rows into dst.
-func (d *Database) QueryAll(db DB, dst interface{}, query string, args ...interface{}) error {
-	// perform the query
-	rows, err := db.Query(query, args...)
+// InsertAll using the Default Database type
+func InsertAll(db DB, table string, src interface{}) error {
+	return Default.InsertAll(db, table, src)
+}
+
+// InsertAllContext using the Default Database type
+func InsertAllContext(ctx context.Context, db DBContext, table string, src interface{}) error {
+	return Default.InsertAllContext(ctx, db, table, src)
+}
+
+// Upsert performs an INSERT query for the given record, which turns into an
+// UPDATE of the existing row if the insert conflicts with it. conflictColumns
+// names the columns of the unique constraint that detects the conflict,
+// defaulting to the primary key columns; MySQL ignores them and reacts to
+// any unique key. Every inserted column except the conflict columns and the
+// primary key is overwritten in the existing row. Afterwards, a primary key
+// supplied by the database is set to the key of the row that was inserted
+// or updated. An existing row keeps its created columns. A version column
+// starts at 1 for a new row and is incremented for an existing one, but
+// the version field is only valid after an insert, so a record that
+// updated an existing row must be reloaded before Update. Of the hooks,
+// only BeforeSave is called, since it is not known whether the row is
+// inserted or updated.
+func (d *Database) Upsert(db DB, table string, src interface{}, conflictColumns ...string) error {
+	return d.UpsertContext(context.Background(), withContext(db), table, src, conflictColumns...)
+}
+
+// UpsertContext is the context-aware version of Upsert.
+func (d *Database) UpsertContext(ctx context.Context, db DBContext, table string, src interface{}, conflictColumns ...string) (err error) {
+	syntax := d.dialect().UpsertSyntax()
+	if syntax == UpsertNone {
+		return fmt.Errorf("meddler.Upsert: %w", ErrNotSupported)
+	}
+	if err := d.checkIdentifiers("meddler.Upsert", table, src); err != nil {
+		return err
+	}
+	if err := beforeSave(ctx, db, src); err != nil {
+		return err
+	}
+	data, err := getFields(reflect.TypeOf(src))
 	if err != nil {
 		return err
 	}
 
-	// gather the results
-	return d.ScanAll(rows, dst)
-}
+	// an upsert that fails before the row is written leaves the fields
+	// meddler sets as they were
+	backup, err := backupFields(src, insertSets)
+	if err != nil {
+		return err
+	}
+	written := false
+	defer func() {
+		if err != nil && !written {
+			backup.restore()
+		}
+	}()
 
-// QueryAll using the Default Database type
-func QueryAll(db DB, dst interface{}, query string, args ...interface{}) error {
-	return Default.QueryAll(db, dst, query, args...)
+	if err := initVersion(src); err != nil {
+		return err
+	}
+	if _, err := d.touch(src, true); err != nil {
+		return err
+	}
+	if err := d.validateInsert(src); err != nil {
+		return err
+	}
+
+	// an integer key that is already set is written like any other
+	// column, but otherwise keys are treated the same way as in Insert
+	pkName, includePk := "", len(data.pk) > 1
+	if len(data.pk) == 1 {
+		_, pkValue, err := d.PrimaryKeyValue(src)
+		if err != nil {
+			return err
+		}
+		key := reflect.ValueOf(pkValue)
+		if isIntegerKey(key.Type()) && !isZeroKey(key) {
+			includePk = true
+		} else if pkName, err = d.insertKey(ctx, db, src); err != nil {
+			return err
+		} else {
+			includePk = pkName == ""
+		}
+	}
+
+	names, err := d.Columns(src, includePk)
+	if err != nil {
+		return err
+	}
+	if len(conflictColumns) == 0 && includePk {
+		conflictColumns = data.pk
+	}
+	if len(conflictColumns) == 0 && syntax == UpsertOnConflict {
+		return fmt.Errorf("meddler.Upsert: %w", ErrNoConflictColumns)
+	}
+	conflict := make(map[string]bool)
+	for _, name := range conflictColumns {
+		if _, present := data.fields[name]; !present {
+			return &FieldError{Op: "meddler.Upsert", Column: name, Err: ErrColumnNotFound}
+		}
+		conflict[name] = true
+	}
+
+	// gather the query parts
+	var quoted, placeholders, updates []string
+	for i, name := range names {
+		quoted = append(quoted, d.quoted(name))
+		placeholders = append(placeholders, d.placeholder(i+1))
+		if name == data.version {
+			// an existing row keeps counting from its own version
+			updates = append(updates, fmt.Sprintf("%s=%s.%s+1", d.quoted(name), d.QuoteTable(table), d.quoted(name)))
+		} else if !conflict[name] && !data.fields[name].primaryKey && (!data.fields[name].created || data.fields[name].updated) {
+			updates = append(updates, d.upsertUpdate(syntax, name))
+		}
+	}
+	output, returning, useReturning := d.dialect().InsertReturning(d.quoted(pkName))
+	if pkName == "" {
+		output, returning = "", ""
+	}
+	if syntax == UpsertOnDuplicateKey && pkName != "" && !useReturning {
+		// make LastInsertId report the key of an updated row too
+		updates = append(updates, fmt.Sprintf("%s=LAST_INSERT_ID(%s)", d.quoted(pkName), d.quoted(pkName)))
+	}
+	if len(updates) == 0 {
+		// the row must still be touched so the key can be read back, so
+		// assign it a value it already has: MySQL may have matched any
+		// unique key, so only the existing key itself is safe there
+		switch {
+		case syntax == UpsertOnConflict:
+			updates = append(updates, d.upsertUpdate(syntax, conflictColumns[0]))
+		case len(data.pk) > 0:
+			updates = append(updates, fmt.Sprintf("%s=%s", d.quoted(data.pk[0]), d.quoted(data.pk[0])))
+		default:
+			return &ConfigError{Type: reflect.TypeOf(src), Msg: "meddler.Upsert called with nothing to update and no key columns"}
+		}
+	}
+	values, err := d.ValuesContext(ctx, src, includePk)
+	if err != nil {
+		return err
+	}
+
+	q := fmt.Sprintf("INSERT INTO %s (%s)%s VALUES (%s)", d.QuoteTable(table), strings.Join(quoted, ","), output, strings.Join(placeholders, ","))
+	switch syntax {
+	case UpsertOnConflict:
+		var target []string
+		for _, name := range conflictColumns {
+			target = append(target, d.quoted(name))
+		}
+		q += fmt.Sprintf(" ON CONFLICT (%s) DO UPDATE SET %s", strings.Join(target, ","), strings.Join(updates, ","))
+	case UpsertOnDuplicateKey:
+		q += " ON DUPLICATE KEY UPDATE " + strings.Join(updates, ",")
+	default:
+		return fmt.Errorf("meddler.Upsert: %w: unknown upsert syntax %d", ErrNotSupported, syntax)
+	}
+
+	// run the query
+	switch {
+	case pkName == "":
+		if _, err := db.ExecContext(ctx, q, values...); err != nil {
+			return &QueryError{Op: "meddler.Upsert", Table: table, SQL: q, Err: err}
+		}
+
+	case useReturning:
+		q += returning
+		targets, err := d.TargetsContext(ctx, src, []string{pkName})
+		if err != nil {
+			return err
+		}
+		if err := db.QueryRowContext(ctx, q, values...).Scan(targets...); err != nil {
+			return &QueryError{Op: "meddler.Upsert", Table: table, SQL: q, Err: err}
+		}
+		if err := d.WriteTargetsContext(ctx, src, []string{pkName}, targets); err != nil {
+			return fmt.Errorf("meddler.Upsert: Error saving updated pk: %w", err)
+		}
+
+	case syntax == UpsertOnDuplicateKey:
+		result, err := db.ExecContext(ctx, q, values...)
+		if err != nil {
+			return &QueryError{Op: "meddler.Upsert", Table: table, SQL: q, Err: err}
+		}
+		newPk, err := result.LastInsertId()
+		if err != nil {
+			return &QueryError{Op: "meddler.Upsert", Table: table, SQL: q, Err: err}
+		}
+		if err = d.SetPrimaryKey(src, newPk); err != nil {
+			return fmt.Errorf("meddler.Upsert: Error saving updated pk: %w", err)
+		}
+
+	default:
+		// LastInsertId is not reliable after an update, so
+		// look up the key of the row using the conflict columns
+		if _, err := db.ExecContext(ctx, q, values...); err != nil {
+			return &QueryError{Op: "meddler.Upsert", Table: table, SQL: q, Err: err}
+		}
+		keys, err := d.SomeValuesContext(ctx, src, conflictColumns)
+		if err != nil {
+			return err
+		}
+		q = fmt.Sprintf("SELECT %s FROM %s WHERE %s", d.quoted(pkName), d.QuoteTable(table), d.whereKeys(conflictColumns, 1))
+		targets, err := d.TargetsContext(ctx, src, []string{pkName})
+		if err != nil {
+			return err
+		}
+		if err := db.QueryRowContext(ctx, q, keys...).Scan(targets...); err != nil {
+			return &QueryError{Op: "meddler.Upsert", Table: table, SQL: q, Err: err}
+		}
+		if err := d.WriteTargetsContext(ctx, src, []string{pkName}, targets); err != nil {
+			return fmt.Errorf("meddler.Upsert: Error saving updated pk: %w", err)
+		}
+	}
+	written = true
+
+	return d.snapshotAll(ctx, src)
+}
+
+// upsertUpdate forms the assignment that copies a column from the
+// rejected insert into the existing row.
+func (d *Database) upsertUpdate(syntax UpsertSyntax, name string) string {
+	if syntax == UpsertOnDuplicateKey {
+		return fmt.Sprintf("%s=VALUES(%s)", d.quoted(name), d.quoted(name))
+	}
+	return fmt.Sprintf("%s=excluded.%s", d.quoted(name), d.quoted(name))
+}
+
+// Upsert using the Default Database type
+func Upsert(db DB, table string, src interface{}, conflictColumns ...string) error {
+	return Default.Upsert(db, table, src, conflictColumns...)
+}
+
+// UpsertContext using the Default Database type
+func UpsertContext(ctx context.Context, db DBContext, table string, src interface{}, conflictColumns ...string) error {
+	return Default.UpsertContext(ctx, db, table, src, conflictColumns...)
+}
+
+// Update performs and UPDATE query for the given record.
+// The record must have a primary key field that is set, which means an
+// integer key greater than zero or a non-zero value of any other key
+// type, or a composite primary key, and it will be used to select the
+// database row that gets updated. Fields marked as created are not
+// written, and fields marked as updated are set to the current time and
+// always written. If the record has a version field, the row is only
+// updated if its version still matches, and the version is incremented;
+// otherwise ErrStaleObject is returned.
+func (d *Database) Update(db DB, table string, src interface{}) error {
+	return d.UpdateContext(context.Background(), withContext(db), table, src)
+}
+
+// UpdateContext is the context-aware version of Update.
+func (d *Database) UpdateContext(ctx context.Context, db DBContext, table string, src interface{}) error {
+	names, err := d.updateColumns(src)
+	if err != nil {
+		return err
+	}
+
+	return d.update(ctx, db, table, src, names)
+}
+
+// updateColumns returns the columns that Update writes: every column
+// except the primary key and the created columns, which keep the values
+// they were given by Insert.
+func (d *Database) updateColumns(src interface{}) ([]string, error) {
+	data, err := getFields(reflect.TypeOf(src))
+	if err != nil {
+		return nil, err
+	}
+	all, err := d.Columns(src, false)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, name := range all {
+		if field := data.fields[name]; !field.created || field.updated {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+// update performs an UPDATE query that writes the named columns of src.
+func (d *Database) update(ctx context.Context, db DBContext, table string, src interface{}, names []string) error {
+	if err := d.checkIdentifiers("meddler.Update", table, src); err != nil {
+		return err
+	}
+	if err := beforeUpdate(ctx, db, src); err != nil {
+		return err
+	}
+	return d.updateRow(ctx, db, table, src, names)
+}
+
+// updateRow does the work of update once the BeforeUpdate hook has run.
+func (d *Database) updateRow(ctx context.Context, db DBContext, table string, src interface{}, names []string) (err error) {
+	version, versionVal, err := versionField(src)
+	if err != nil {
+		return err
+	}
+	if version != "" {
+		// the version column is only ever written by meddler
+		var rest []string
+		for _, name := range names {
+			if name != version {
+				rest = append(rest, name)
+			}
+		}
+		names = rest
+	}
+	if len(names) == 0 && version == "" {
+		return &ConfigError{Type: reflect.TypeOf(src), Msg: "meddler.Update called with no columns to update"}
+	}
+
+	// an update that fails before the row is written leaves the fields
+	// meddler sets as they were
+	backup, err := backupFields(src, updateSets)
+	if err != nil {
+		return err
+	}
+	written := false
+	defer func() {
+		if err != nil && !written {
+			backup.restore()
+		}
+	}()
+
+	// updated fields are always written
+	stamped, err := d.touch(src, false)
+	if err != nil {
+		return err
+	}
+	for _, name := range stamped {
+		found := false
+		for _, elt := range names {
+			found = found || elt == name
+		}
+		if !found {
+			names = append(names[:len(names):len(names)], name)
+		}
+	}
+	if err := validate(src, names); err != nil {
+		return err
+	}
+
+	// gather the query parts
+	values, err := d.SomeValuesContext(ctx, src, names)
+	if err != nil {
+		return err
+	}
+
+	// form the column=placeholder pairs
+	var pairs []string
+	for i, name := range names {
+		pair := fmt.Sprintf("%s=%s", d.quoted(name), d.placeholder(i+1))
+		pairs = append(pairs, pair)
+	}
+
+	pkNames, pkValues, err := d.rowKeys("meddler.Update", src)
+	if err != nil {
+		return err
+	}
+
+	where := d.whereKeys(pkNames, len(names)+1)
+	values = append(values, pkValues...)
+
+	// with a version column, only update the row if nobody else has
+	if version != "" {
+		pairs = append(pairs, fmt.Sprintf("%s=%s+1", d.quoted(version), d.quoted(version)))
+		where += fmt.Sprintf(" AND %s=%s", d.quoted(version), d.placeholder(len(values)+1))
+		values = append(values, versionVal.Interface())
+	}
+
+	// run the query
+	q := fmt.Sprintf("UPDATE %s SET %s WHERE %s", d.QuoteTable(table), strings.Join(pairs, ","), where)
+	result, err := db.ExecContext(ctx, q, values...)
+	if err != nil {
+		return &QueryError{Op: "meddler.Update", Table: table, SQL: q, Err: err}
+	}
+
+	if version != "" {
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return &QueryError{Op: "meddler.Update", Table: table, SQL: q, Err: err}
+		}
+		if rows == 0 {
+			return ErrStaleObject
+		}
+		if versionVal.CanInt() {
+			versionVal.SetInt(versionVal.Int() + 1)
+		} else {
+			versionVal.SetUint(versionVal.Uint() + 1)
+		}
+		names = append(names[:len(names):len(names)], version)
+	}
+	written = true
+
+	if err := d.snapshot(ctx, src, names); err != nil {
+		return err
+	}
+	return afterUpdate(ctx, db, src)
+}
+
+// versionField returns the name of the version column of src and its
+// field, or an empty name if src does not have one.
+func versionField(src interface{}) (string, reflect.Value, error) {
+	data, err := getFields(reflect.TypeOf(src))
+	if err != nil {
+		return "", reflect.Value{}, err
+	}
+	if data.version == "" {
+		return "", reflect.Value{}, nil
+	}
+	return data.version, fieldByIndex(reflect.ValueOf(src).Elem(), data.fields[data.version].index), nil
+}
+
+// touch sets the updated fields of src to the current time, along with
+// the created fields if created is true, and returns their column names.
+func (d *Database) touch(src interface{}, created bool) ([]string, error) {
+	data, err := getFields(reflect.TypeOf(src))
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	var now reflect.Value
+	for _, name := range data.columns {
+		field := data.fields[name]
+		if !field.updated && !(created && field.created) {
+			continue
+		}
+		if !now.IsValid() {
+			now = reflect.ValueOf(d.now())
+		}
+		v := fieldByIndex(reflect.ValueOf(src).Elem(), field.index)
+		if v.Kind() == reflect.Ptr {
+			v.Set(reflect.New(timeType))
+			v = v.Elem()
+		}
+		v.Set(now)
+		names = append(names, name)
+	}
+
+	return names, nil
+}
+
+// now returns the current time according to the Now field.
+func (d *Database) now() time.Time {
+	if d.Now != nil {
+		return d.Now()
+	}
+	return time.Now()
+}
+
+// fieldBackup holds copies of struct fields that meddler is about to
+// set, so they can be put back if the operation fails.
+type fieldBackup struct {
+	fields []reflect.Value
+	values []reflect.Value
+}
+
+// backupFields copies the fields of src that include selects.
+func backupFields(src interface{}, include func(field *structField) bool) (*fieldBackup, error) {
+	data, err := getFields(reflect.TypeOf(src))
+	if err != nil {
+		return nil, err
+	}
+	b := new(fieldBackup)
+	for _, name := range data.columns {
+		field := data.fields[name]
+		if !include(field) {
+			continue
+		}
+		v := fieldByIndex(reflect.ValueOf(src).Elem(), field.index)
+		saved := reflect.New(v.Type()).Elem()
+		saved.Set(v)
+		b.fields = append(b.fields, v)
+		b.values = append(b.values, saved)
+	}
+	return b, nil
+}
+
+// restore puts the copied fields back.
+func (b *fieldBackup) restore() {
+	for i, v := range b.fields {
+		v.Set(b.values[i])
+	}
+}
+
+// insertSets reports whether meddler may set a field while inserting it.
+func insertSets(field *structField) bool {
+	return field.version || field.created || field.updated || field.primaryKey
+}
+
+// updateSets reports whether meddler may set a field while updating it.
+func updateSets(field *structField) bool {
+	return field.updated
+}
+
+// initVersion sets the version field of src, if it has one,
+// to 1 for a newly inserted row.
+func initVersion(src interface{}) error {
+	version, versionVal, err := versionField(src)
+	if err != nil || version == "" {
+		return err
+	}
+	versionVal.Set(reflect.ValueOf(1).Convert(versionVal.Type()))
+	return nil
+}
+
+// rowKeys returns the primary key columns and values that identify the
+// existing database row for src, ready to be used as query arguments.
+// A single primary key must be set: an integer key must be > 0,
+// and any other key must not be the zero value.
+func (d *Database) rowKeys(op string, src interface{}) ([]string, []interface{}, error) {
+	pkNames, pkValues, err := d.PrimaryKeys(src)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(pkNames) == 0 {
+		return nil, nil, fmt.Errorf("%s: %w", op, ErrNoPrimaryKey)
+	}
+	if len(pkNames) == 1 {
+		key := reflect.ValueOf(pkValues[0])
+		if isIntegerKey(key.Type()) {
+			_, pkValue, err := d.PrimaryKey(src)
+			if err != nil {
+				return nil, nil, err
+			}
+			if pkValue < 1 {
+				return nil, nil, fmt.Errorf("%s: %w: an integer key must be > 0", op, ErrPrimaryKeyNotSet)
+			}
+		} else if isZeroKey(key) {
+			return nil, nil, fmt.Errorf("%s: %w", op, ErrPrimaryKeyNotSet)
+		}
+	}
+
+	for i, pkValue := range pkValues {
+		pkValues[i] = byteArrayArg(pkValue)
+	}
+	return pkNames, pkValues, nil
+}
+
+// Update using the Default Database type
+func Update(db DB, table string, src interface{}) error {
+	return Default.Update(db, table, src)
+}
+
+// UpdateContext using the Default Database type
+func UpdateContext(ctx context.Context, db DBContext, table string, src interface{}) error {
+	return Default.UpdateContext(ctx, db, table, src)
+}
+
+// UpdateColumns performs an UPDATE query for the given record, like Update,
+// but only writes the named columns. Naming a column that the struct does
+// not have, or a primary key column, is an error.
+func (d *Database) UpdateColumns(db DB, table string, src interface{}, columns ...string) error {
+	return d.UpdateColumnsContext(context.Background(), withContext(db), table, src, columns...)
+}
+
+// UpdateColumnsContext is the context-aware version of UpdateColumns.
+func (d *Database) UpdateColumnsContext(ctx context.Context, db DBContext, table string, src interface{}, columns ...string) error {
+	if err := d.checkUpdateColumns("meddler.UpdateColumns", src, columns); err != nil {
+		return err
+	}
+
+	return d.update(ctx, db, table, src, columns)
+}
+
+// UpdateColumns using the Default Database type
+func UpdateColumns(db DB, table string, src interface{}, columns ...string) error {
+	return Default.UpdateColumns(db, table, src, columns...)
+}
+
+// UpdateColumnsContext using the Default Database type
+func UpdateColumnsContext(ctx context.Context, db DBContext, table string, src interface{}, columns ...string) error {
+	return Default.UpdateColumnsContext(ctx, db, table, src, columns...)
+}
+
+// UpdateExcept performs an UPDATE query for the given record, like Update,
+// but writes every column except the named ones. Naming a column that the
+// struct does not have, or a primary key column, is an error.
+func (d *Database) UpdateExcept(db DB, table string, src interface{}, columns ...string) error {
+	return d.UpdateExceptContext(context.Background(), withContext(db), table, src, columns...)
+}
+
+// UpdateExceptContext is the context-aware version of UpdateExcept.
+func (d *Database) UpdateExceptContext(ctx context.Context, db DBContext, table string, src interface{}, columns ...string) error {
+	if err := d.checkUpdateColumns("meddler.UpdateExcept", src, columns); err != nil {
+		return err
+	}
+	skip := make(map[string]bool)
+	for _, name := range columns {
+		skip[name] = true
+	}
+
+	all, err := d.updateColumns(src)
+	if err != nil {
+		return err
+	}
+	var names []string
+	for _, name := range all {
+		if !skip[name] {
+			names = append(names, name)
+		}
+	}
+
+	return d.update(ctx, db, table, src, names)
+}
+
+// UpdateExcept using the Default Database type
+func UpdateExcept(db DB, table string, src interface{}, columns ...string) error {
+	return Default.UpdateExcept(db, table, src, columns...)
+}
+
+// UpdateExceptContext using the Default Database type
+func UpdateExceptContext(ctx context.Context, db DBContext, table string, src interface{}, columns ...string) error {
+	return Default.UpdateExceptContext(ctx, db, table, src, columns...)
+}
+
+// checkUpdateColumns makes sure each named column belongs to a
+// non-primary key field of src, and is only named once.
+func (d *Database) checkUpdateColumns(op string, src interface{}, columns []string) error {
+	data, err := getFields(reflect.TypeOf(src))
+	if err != nil {
+		return err
+	}
+	seen := make(map[string]bool)
+	for _, name := range columns {
+		field, present := data.fields[name]
+		if !present {
+			return &FieldError{Op: op, Column: name, Err: ErrColumnNotFound}
+		}
+		if seen[name] {
+			return &FieldError{Op: op, Field: field.name, Column: name, Err: fmt.Errorf("%w: it is named more than once", ErrColumnNotUpdatable)}
+		}
+		seen[name] = true
+		if field.primaryKey {
+			return &FieldError{Op: op, Field: field.name, Column: name, Err: fmt.Errorf("%w: it is part of the primary key", ErrColumnNotUpdatable)}
+		}
+		if field.version {
+			return &FieldError{Op: op, Field: field.name, Column: name, Err: fmt.Errorf("%w: it is the version column", ErrColumnNotUpdatable)}
+		}
+	}
+
+	return nil
+}
+
+// Save performs an INSERT or an UPDATE, depending on whether or not
+// a primary keys exists and is non-zero. A primary key of any type counts
+// as zero if it holds the zero value of its type. Save cannot be used with
+// a composite primary key.
+func (d *Database) Save(db DB, table string, src interface{}) error {
+	return d.SaveContext(context.Background(), withContext(db), table, src)
+}
+
+// SaveContext is the context-aware version of Save.
+func (d *Database) SaveContext(ctx context.Context, db DBContext, table string, src interface{}) error {
+	pkNames, _, err := d.PrimaryKeys(src)
+	if err != nil {
+		return err
+	}
+	if len(pkNames) > 1 {
+		return fmt.Errorf("meddler.Save: %w, so it cannot choose between Insert and Update", ErrCompositePrimaryKey)
+	}
+	if err := beforeSave(ctx, db, src); err != nil {
+		return err
+	}
+
+	pkName, pkValue, err := d.PrimaryKeyValue(src)
+	if err != nil {
+		return err
+	}
+	if pkName != "" && !isZeroKey(reflect.ValueOf(pkValue)) {
+		return d.UpdateContext(ctx, db, table, src)
+	}
+
+	return d.InsertContext(ctx, db, table, src)
+}
+
+// Save using the Default Database type
+func Save(db DB, table string, src interface{}) error {
+	return Default.Save(db, table, src)
+}
+
+// SaveContext using the Default Database type
+func SaveContext(ctx context.Context, db DBContext, table string, src interface{}) error {
+	return Default.SaveContext(ctx, db, table, src)
+}
+
+// Delete performs a DELETE query for the given record, using its primary
+// key to select the database row that gets deleted. The primary key must
+// be set, as in Update. Returns ErrNoRowsAffected if no row was deleted.
+// If the record has a softdelete field, the row is not removed. Instead,
+// the field is set to the current time (or true, for a bool field) in both
+// the row and the record, and Load will no longer find it.
+func (d *Database) Delete(db DB, table string, src interface{}) error {
+	return d.DeleteContext(context.Background(), withContext(db), table, src)
+}
+
+// DeleteContext is the context-aware version of Delete.
+func (d *Database) DeleteContext(ctx context.Context, db DBContext, table string, src interface{}) error {
+	pkNames, pkValues, err := d.rowKeys("meddler.Delete", src)
+	if err != nil {
+		return err
+	}
+	deleted, err := d.deleteByKey(ctx, db, table, src, pkNames, pkValues)
+	if err != nil {
+		return err
+	}
+
+	// record a soft delete in the struct as well
+	if deleted.IsValid() {
+		field, _, err := softDeleteField(src)
+		if err != nil {
+			return err
+		}
+		fieldByIndex(reflect.ValueOf(src).Elem(), field.index).Set(deleted)
+	}
+
+	return nil
+}
+
+// Delete using the Default Database type
+func Delete(db DB, table string, src interface{}) error {
+	return Default.Delete(db, table, src)
+}
+
+// DeleteContext using the Default Database type
+func DeleteContext(ctx context.Context, db DBContext, table string, src interface{}) error {
+	return Default.DeleteContext(ctx, db, table, src)
+}
+
+// DeleteByPK deletes a record using a query for the primary key field.
+// dst is only used to find the name of the primary key column, and is
+// not changed. Returns ErrNoRowsAffected if no row was deleted. A
+// softdelete field is handled as in Delete.
+func (d *Database) DeleteByPK(db DB, table string, dst interface{}, pk int64) error {
+	return d.DeleteByPKContext(context.Background(), withContext(db), table, dst, pk)
+}
+
+// DeleteByPKContext is the context-aware version of DeleteByPK.
+func (d *Database) DeleteByPKContext(ctx context.Context, db DBContext, table string, dst interface{}, pk int64) error {
+	return d.DeleteByKeyContext(ctx, db, table, dst, pk)
+}
+
+// DeleteByPK using the Default Database type
+func DeleteByPK(db DB, table string, dst interface{}, pk int64) error {
+	return Default.DeleteByPK(db, table, dst, pk)
+}
+
+// DeleteByPKContext using the Default Database type
+func DeleteByPKContext(ctx context.Context, db DBContext, table string, dst interface{}, pk int64) error {
+	return Default.DeleteByPKContext(ctx, db, table, dst, pk)
+}
+
+// DeleteByKey deletes a record using a query for all of the primary key
+// fields, with key values given in the same order as in LoadByKey.
+// Returns ErrNoRowsAffected if no row was deleted. A softdelete field is
+// handled as in Delete.
+func (d *Database) DeleteByKey(db DB, table string, dst interface{}, keys ...interface{}) error {
+	return d.DeleteByKeyContext(context.Background(), withContext(db), table, dst, keys...)
+}
+
+// DeleteByKeyContext is the context-aware version of DeleteByKey.
+func (d *Database) DeleteByKeyContext(ctx context.Context, db DBContext, table string, dst interface{}, keys ...interface{}) error {
+	pkNames, _, err := d.PrimaryKeys(dst)
+	if err != nil {
+		return err
+	}
+	if len(pkNames) == 0 {
+		return fmt.Errorf("meddler.Delete: %w", ErrNoPrimaryKey)
+	}
+	if len(keys) != len(pkNames) {
+		return fmt.Errorf("meddler.Delete: %w: struct has %d primary key fields, but %d key values were given", ErrKeyCount, len(pkNames), len(keys))
+	}
+
+	args := make([]interface{}, len(keys))
+	for i, key := range keys {
+		args[i] = byteArrayArg(key)
+	}
+	_, err = d.deleteByKey(ctx, db, table, dst, pkNames, args)
+	return err
+}
+
+// DeleteByKey using the Default Database type
+func DeleteByKey(db DB, table string, dst interface{}, keys ...interface{}) error {
+	return Default.DeleteByKey(db, table, dst, keys...)
+}
+
+// DeleteByKeyContext using the Default Database type
+func DeleteByKeyContext(ctx context.Context, db DBContext, table string, dst interface{}, keys ...interface{}) error {
+	return Default.DeleteByKeyContext(ctx, db, table, dst, keys...)
+}
+
+// HardDelete performs a DELETE query for the given record like Delete,
+// but always removes the row, even if the record has a softdelete field.
+func (d *Database) HardDelete(db DB, table string, src interface{}) error {
+	return d.HardDeleteContext(context.Background(), withContext(db), table, src)
+}
+
+// HardDeleteContext is the context-aware version of HardDelete.
+func (d *Database) HardDeleteContext(ctx context.Context, db DBContext, table string, src interface{}) error {
+	if err := d.checkIdentifiers("meddler.HardDelete", table, src); err != nil {
+		return err
+	}
+	pkNames, pkValues, err := d.rowKeys("meddler.HardDelete", src)
+	if err != nil {
+		return err
+	}
+	q := fmt.Sprintf("DELETE FROM %s WHERE %s", d.QuoteTable(table), d.whereKeys(pkNames, 1))
+	return execRow(ctx, db, "meddler.HardDelete", table, q, pkValues)
+}
+
+// HardDelete using the Default Database type
+func HardDelete(db DB, table string, src interface{}) error {
+	return Default.HardDelete(db, table, src)
+}
+
+// HardDeleteContext using the Default Database type
+func HardDeleteContext(ctx context.Context, db DBContext, table string, src interface{}) error {
+	return Default.HardDeleteContext(ctx, db, table, src)
+}
+
+// Restore undoes a soft delete of the given record, clearing its softdelete
+// field in both the row and the record. The record must have a softdelete
+// field and a primary key that is set, as in Update. Returns
+// ErrNoRowsAffected if there was no deleted row to restore.
+func (d *Database) Restore(db DB, table string, src interface{}) error {
+	return d.RestoreContext(context.Background(), withContext(db), table, src)
+}
+
+// RestoreContext is the context-aware version of Restore.
+func (d *Database) RestoreContext(ctx context.Context, db DBContext, table string, src interface{}) error {
+	if err := d.checkIdentifiers("meddler.Restore", table, src); err != nil {
+		return err
+	}
+	field, fieldType, err := softDeleteField(src)
+	if err != nil {
+		return err
+	}
+	if field == nil {
+		return &ConfigError{Type: reflect.TypeOf(src), Msg: "meddler.Restore called without a softdelete field"}
+	}
+	pkNames, pkValues, err := d.rowKeys("meddler.Restore", src)
+	if err != nil {
+		return err
+	}
+
+	restored := reflect.Zero(fieldType)
+	arg, err := preWrite(ctx, field.meddler, restored.Interface())
+	if err != nil {
+		return fieldError("PreWrite", field, err)
+	}
+	filter, filterArgs, err := d.softDeleteFilter(ctx, field, fieldType, true, d.placeholder(len(pkNames)+2))
+	if err != nil {
+		return err
+	}
+
+	q := fmt.Sprintf("UPDATE %s SET %s=%s WHERE %s AND %s", d.QuoteTable(table),
+		d.quoted(field.column), d.placeholder(1),
+		d.whereKeys(pkNames, 2), filter)
+	args := append(append([]interface{}{arg}, pkValues...), filterArgs...)
+	if err := execRow(ctx, db, "meddler.Restore", table, q, args); err != nil {
+		return err
+	}
+
+	fieldByIndex(reflect.ValueOf(src).Elem(), field.index).Set(restored)
+	return nil
+}
+
+// Restore using the Default Database type
+func Restore(db DB, table string, src interface{}) error {
+	return Default.Restore(db, table, src)
+}
+
+// RestoreContext using the Default Database type
+func RestoreContext(ctx context.Context, db DBContext, table string, src interface{}) error {
+	return Default.RestoreContext(ctx, db, table, src)
+}
+
+// deleteByKey deletes the row with the given primary key values, or marks
+// it as deleted if dst has a softdelete field, in which case it returns
+// the value that was written to that field.
+func (d *Database) deleteByKey(ctx context.Context, db DBContext, table string, dst interface{}, pkNames []string, pkValues []interface{}) (reflect.Value, error) {
+	if err := d.checkIdentifiers("meddler.Delete", table, dst); err != nil {
+		return reflect.Value{}, err
+	}
+	field, fieldType, err := softDeleteField(dst)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	if field == nil {
+		q := fmt.Sprintf("DELETE FROM %s WHERE %s", d.QuoteTable(table), d.whereKeys(pkNames, 1))
+		return reflect.Value{}, execRow(ctx, db, "meddler.Delete", table, q, pkValues)
+	}
+
+	var deleted reflect.Value
+	switch fieldType {
+	case timeType:
+		deleted = reflect.ValueOf(d.now())
+	case reflect.PtrTo(timeType):
+		now := d.now()
+		deleted = reflect.ValueOf(&now)
+	default:
+		deleted = reflect.ValueOf(true).Convert(fieldType)
+	}
+	arg, err := preWrite(ctx, field.meddler, deleted.Interface())
+	if err != nil {
+		return reflect.Value{}, fieldError("PreWrite", field, err)
+	}
+
+	// rows that are already deleted keep their original value
+	filter, filterArgs, err := d.softDeleteFilter(ctx, field, fieldType, false, d.placeholder(len(pkNames)+2))
+	if err != nil {
+		return reflect.Value{}, err
+	}
+
+	q := fmt.Sprintf("UPDATE %s SET %s=%s WHERE %s AND %s", d.QuoteTable(table),
+		d.quoted(field.column), d.placeholder(1),
+		d.whereKeys(pkNames, 2), filter)
+	args := append(append([]interface{}{arg}, pkValues...), filterArgs...)
+	if err := execRow(ctx, db, "meddler.Delete", table, q, args); err != nil {
+		return reflect.Value{}, err
+	}
+
+	return deleted, nil
+}
+
+// softDeleteField returns the softdelete field of dst along with its type,
+// or nil if dst does not have one.
+func softDeleteField(dst interface{}) (*structField, reflect.Type, error) {
+	data, err := getFields(reflect.TypeOf(dst))
+	if err != nil {
+		return nil, nil, err
+	}
+	if data.softDelete == "" {
+		return nil, nil, nil
+	}
+	field := data.fields[data.softDelete]
+	return field, reflect.TypeOf(dst).Elem().FieldByIndex(field.index).Type, nil
+}
+
+// softDeleteFilter returns a condition that matches rows that have been
+// soft deleted, or rows that have not if deleted is false, along with its
+// arguments, using placeholder for the argument if it needs one. A row
+// has not been deleted if its column holds the zero value of the field,
+// which is usually NULL or false once it has been through the meddler.
+func (d *Database) softDeleteFilter(ctx context.Context, field *structField, fieldType reflect.Type, deleted bool, placeholder string) (string, []interface{}, error) {
+	zero, err := preWrite(ctx, field.meddler, reflect.Zero(fieldType).Interface())
+	if err != nil {
+		return "", nil, fieldError("PreWrite", field, err)
+	}
+	if v, err := driver.DefaultParameterConverter.ConvertValue(zero); err == nil && v == nil {
+		if deleted {
+			return d.quoted(field.column) + " IS NOT NULL", nil, nil
+		}
+		return d.quoted(field.column) + " IS NULL", nil, nil
+	}
+
+	op := "="
+	if deleted {
+		op = "<>"
+	}
+	return fmt.Sprintf("%s%s%s", d.quoted(field.column), op, placeholder), []interface{}{zero}, nil
+}
+
+// SoftDeleteFilter returns a condition that skips the rows that have been
+// soft deleted, as Load does, for a hand-written query on the table of
+// model, which is a pointer to a struct. The condition uses a ? placeholder
+// if it needs an argument, which is returned too. The condition is "" if
+// model has no softdelete field.
+func (d *Database) SoftDeleteFilter(model interface{}) (string, []interface{}, error) {
+	field, fieldType, err := softDeleteField(model)
+	if err != nil || field == nil {
+		return "", nil, err
+	}
+	return d.softDeleteFilter(context.Background(), field, fieldType, false, "?")
+}
+
+// SoftDeleteFilter using the Default Database type
+func SoftDeleteFilter(model interface{}) (string, []interface{}, error) {
+	return Default.SoftDeleteFilter(model)
+}
+
+// execRow runs a query that is expected to change a single row, and
+// returns ErrNoRowsAffected if it did not change any.
+func execRow(ctx context.Context, db DBContext, op, table, q string, args []interface{}) error {
+	result, err := db.ExecContext(ctx, q, args...)
+	if err != nil {
+		return &QueryError{Op: op, Table: table, SQL: q, Err: err}
+	}
+	count, err := result.RowsAffected()
+	if err != nil {
+		return &QueryError{Op: op, Table: table, SQL: q, Err: err}
+	}
+	if count == 0 {
+		return ErrNoRowsAffected
+	}
+
+	return nil
+}
+
+// QueryRow performs the given query with the given arguments, scanning a
+// single row of results into dst. Returns sql.ErrNoRows if there was no
+// result row. InList arguments are expanded, and the placeholders are
+// rebound first if AutoRebind is set.
+func (d *Database) QueryRow(db DB, dst interface{}, query string, args ...interface{}) error {
+	return d.QueryRowContext(context.Background(), withContext(db), dst, query, args...)
+}
+
+// QueryRowContext is the context-aware version of QueryRow.
+func (d *Database) QueryRowContext(ctx context.Context, db DBContext, dst interface{}, query string, args ...interface{}) error {
+	// perform the query
+	query, args = d.userQuery(query, args)
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return &QueryError{Op: "meddler.QueryRow", SQL: query, Err: err}
+	}
+
+	// gather the result
+	return d.ScanRowContext(ctx, rows, dst)
+}
+
+// QueryRow using the Default Database type
+func QueryRow(db DB, dst interface{}, query string, args ...interface{}) error {
+	return Default.QueryRow(db, dst, query, args...)
+}
+
+// QueryRowContext using the Default Database type
+func QueryRowContext(ctx context.Context, db DBContext, dst interface{}, query string, args ...interface{}) error {
+	return Default.QueryRowContext(ctx, db, dst, query, args...)
+}
+
+// QueryAll performs the given query with the given arguments, scanning
+// all results rows into dst. InList arguments are expanded, and the
+// placeholders are rebound first if AutoRebind is set.
+func (d *Database) QueryAll(db DB, dst interface{}, query string, args ...interface{}) error {
+	return d.QueryAllContext(context.Background(), withContext(db), dst, query, args...)
+}
+
+// QueryAllContext is the context-aware version of QueryAll.
+func (d *Database) QueryAllContext(ctx context.Context, db DBContext, dst interface{}, query string, args ...interface{}) error {
+	// perform the query
+	query, args = d.userQuery(query, args)
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return &QueryError{Op: "meddler.QueryAll", SQL: query, Err: err}
+	}
+
+	// gather the results
+	return d.ScanAllContext(ctx, rows, dst)
+}
+
+// QueryAll using the Default Database type
+func QueryAll(db DB, dst interface{}, query string, args ...interface{}) error {
+	return Default.QueryAll(db, dst, query, args...)
+}
+
+// QueryAllContext using the Default Database type
+func QueryAllContext(ctx context.Context, db DBContext, dst interface{}, query string, args ...interface{}) error {
+	return Default.QueryAllContext(ctx, db, dst, query, args...)
 }
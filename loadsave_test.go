@@ -1,7 +1,13 @@
 package meddler
 
 import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
 	"io"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -48,6 +54,147 @@ func TestNoPrimaryKey(t *testing.T) {
 	}
 }
 
+type Membership struct {
+	PersonID int64  `meddler:"person_id,pk"`
+	GroupID  int64  `meddler:"group_id,pk"`
+	Role     string `meddler:"role"`
+}
+
+func TestCompositeKey(t *testing.T) {
+	once.Do(setup)
+
+	m := &Membership{PersonID: 1, GroupID: 2, Role: "member"}
+	if err := Insert(db, "membership", m); err != nil {
+		t.Fatalf("Insert error: %v", err)
+	}
+	if err := Insert(db, "membership", &Membership{PersonID: 1, GroupID: 3, Role: "owner"}); err != nil {
+		t.Fatalf("Insert error: %v", err)
+	}
+
+	m.Role = "admin"
+	if err := Update(db, "membership", m); err != nil {
+		t.Errorf("Update error: %v", err)
+	}
+
+	elt := new(Membership)
+	if err := LoadByKey(db, "membership", elt, 1, 2); err != nil {
+		t.Errorf("LoadByKey error: %v", err)
+	}
+	if *elt != *m {
+		t.Errorf("LoadByKey: expected %+v, found %+v", m, elt)
+	}
+	if err := LoadByKey(db, "membership", elt, 1, 3); err != nil {
+		t.Errorf("LoadByKey error: %v", err)
+	}
+	if elt.Role != "owner" {
+		t.Errorf("Update changed the wrong row: found role %s", elt.Role)
+	}
+
+	if err := LoadByKey(db, "membership", elt, 1, 4); err != sql.ErrNoRows {
+		t.Errorf("LoadByKey on missing row: expected sql.ErrNoRows, got %v", err)
+	}
+	if err := LoadByKey(db, "membership", elt, 1); err == nil {
+		t.Errorf("LoadByKey with too few keys: expected err, got nil")
+	}
+	if err := Load(db, "membership", elt, 1); err == nil {
+		t.Errorf("Load on composite key: expected err, got nil")
+	}
+	if err := Save(db, "membership", m); err == nil {
+		t.Errorf("Save on composite key: expected err, got nil")
+	}
+	db.Exec("delete from membership")
+}
+
+type Token struct {
+	ID    string `meddler:"id,pk"`
+	Label string `meddler:"label"`
+}
+
+var tokenCount int
+
+func (tok *Token) GenerateKey() (interface{}, error) {
+	tokenCount++
+	return fmt.Sprintf("tok-%d", tokenCount), nil
+}
+
+type Device struct {
+	ID    [16]byte `meddler:"id,pk"`
+	Label string   `meddler:"label"`
+}
+
+func TestStringKey(t *testing.T) {
+	once.Do(setup)
+
+	tok := &Token{Label: "first"}
+	if err := Save(db, "token", tok); err != nil {
+		t.Fatalf("Save error: %v", err)
+	}
+	if tok.ID == "" {
+		t.Fatalf("Save did not generate a key")
+	}
+	id := tok.ID
+
+	tok.Label = "second"
+	if err := Save(db, "token", tok); err != nil {
+		t.Errorf("Save error: %v", err)
+	}
+	if tok.ID != id {
+		t.Errorf("Save changed key from %s to %s", id, tok.ID)
+	}
+
+	elt := new(Token)
+	if err := LoadByKey(db, "token", elt, id); err != nil {
+		t.Errorf("LoadByKey error: %v", err)
+	}
+	if *elt != *tok {
+		t.Errorf("LoadByKey: expected %+v, found %+v", tok, elt)
+	}
+
+	// a key that is already set is inserted as is
+	if err := Insert(db, "token", &Token{ID: "mine", Label: "third"}); err != nil {
+		t.Errorf("Insert error: %v", err)
+	}
+	if err := LoadByKey(db, "token", elt, "mine"); err != nil {
+		t.Errorf("LoadByKey error: %v", err)
+	}
+	if elt.Label != "third" {
+		t.Errorf("LoadByKey: expected label third, found %s", elt.Label)
+	}
+	db.Exec("delete from token")
+}
+
+func TestByteArrayKey(t *testing.T) {
+	once.Do(setup)
+
+	id := [16]byte{0xde, 0xad, 0xbe, 0xef, 15: 1}
+	dev := &Device{ID: id, Label: "sensor"}
+	if err := Insert(db, "device", dev); err != nil {
+		t.Fatalf("Insert error: %v", err)
+	}
+
+	dev.Label = "probe"
+	if err := Save(db, "device", dev); err != nil {
+		t.Errorf("Save error: %v", err)
+	}
+
+	elt := new(Device)
+	if err := LoadByKey(db, "device", elt, id); err != nil {
+		t.Errorf("LoadByKey error: %v", err)
+	}
+	if *elt != *dev {
+		t.Errorf("LoadByKey: expected %+v, found %+v", dev, elt)
+	}
+
+	// without a KeyGenerator there is no way to get a new key from SQLite
+	if err := Insert(db, "device", &Device{Label: "nameless"}); err == nil {
+		t.Errorf("Insert with zero key and no generator: expected err, got nil")
+	}
+	if err := Update(db, "device", &Device{Label: "nameless"}); err == nil {
+		t.Errorf("Update with zero key: expected err, got nil")
+	}
+	db.Exec("delete from device")
+}
+
 func TestLoadUint(t *testing.T) {
 	once.Do(setup)
 	insertAliceBob(t)
@@ -191,3 +338,703 @@ func TestDriverErr(t *testing.T) {
 		t.Errorf("update with primary key 0. want error, got none")
 	}
 }
+
+func TestContext(t *testing.T) {
+	once.Do(setup)
+	insertAliceBob(t)
+	ctx := context.Background()
+
+	elt := new(Person)
+	if err := LoadContext(ctx, db, "person", elt, 2); err != nil {
+		t.Errorf("LoadContext error on Bob: %v", err)
+	}
+	bob.ID = 2
+	personEqual(t, elt, bob)
+
+	var people []*Person
+	if err := QueryAllContext(ctx, db, &people, "select * from person order by id"); err != nil {
+		t.Errorf("QueryAllContext error: %v", err)
+	}
+	if len(people) != 2 {
+		t.Errorf("QueryAllContext: expected %d results, got %d", 2, len(people))
+	}
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatalf("DB error getting conn: %v", err)
+	}
+	elt.ID = 0
+	elt.Name = "Bobby"
+	if err := SaveContext(ctx, conn, "person", elt); err != nil {
+		t.Errorf("SaveContext error on *sql.Conn: %v", err)
+	}
+	if elt.ID != 3 {
+		t.Errorf("SaveContext: expected ID of 3, got %d", elt.ID)
+	}
+	conn.Close()
+
+	// a cancelled context should stop the query
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := LoadContext(cancelled, db, "person", elt, 2); err == nil {
+		t.Errorf("LoadContext with cancelled context: expected err, got nil")
+	}
+	elt.Name = "Robert"
+	if err := UpdateContext(cancelled, db, "person", elt); err == nil {
+		t.Errorf("UpdateContext with cancelled context: expected err, got nil")
+	}
+	db.Exec("delete from person")
+}
+
+func TestInsertAll(t *testing.T) {
+	once.Do(setup)
+
+	// a small limit forces several batches
+	d := &Database{
+		Quote:           `"`,
+		Placeholder:     "?",
+		MaxPlaceholders: 16,
+		BulkInsertID:    BulkInsertIDLast,
+	}
+
+	var people []*Person
+	for i := 0; i < 5; i++ {
+		people = append(people, &Person{
+			Name:   fmt.Sprintf("Person %d", i),
+			Email:  fmt.Sprintf("p%d@example.com", i),
+			Age:    20 + i,
+			Opened: when,
+		})
+	}
+	if err := d.InsertAll(db, "person", people); err != nil {
+		t.Fatalf("InsertAll error: %v", err)
+	}
+	for i, p := range people {
+		if p.ID != int64(i+1) {
+			t.Errorf("Person %d: expected ID %d, found %d", i, i+1, p.ID)
+		}
+		elt := new(Person)
+		if err := Load(db, "person", elt, p.ID); err != nil {
+			t.Errorf("Load error: %v", err)
+			continue
+		}
+		personEqual(t, elt, p)
+	}
+	db.Exec("delete from person")
+
+	// slices of structs work too, and RETURNING fills in the keys
+	d.UseReturningToGetID = true
+	d.MaxPlaceholders = 0
+	values := []Person{
+		{Name: "Alice", Email: "alice@alice.com", Opened: when},
+		{Name: "Bob", Email: "bob@bob.com", Opened: when},
+	}
+	if err := d.InsertAll(db, "person", values); err != nil {
+		t.Fatalf("InsertAll with RETURNING error: %v", err)
+	}
+	if values[0].ID != 1 || values[1].ID != 2 {
+		t.Errorf("InsertAll with RETURNING: expected IDs 1 and 2, found %d and %d", values[0].ID, values[1].ID)
+	}
+	db.Exec("delete from person")
+
+	// PostgreSQL draws the keys from the sequence first, so the rows
+	// still go in together
+	fake := new(fakeDB)
+	sqlDB := fake.open()
+	fake.queue([]string{"nextval"}, []driver.Value{int64(7)}, []driver.Value{int64(8)})
+	tags := []*Tag{{Name: "a"}, {Name: "b"}}
+	if err := PostgreSQL.InsertAll(sqlDB, "tag", tags); err != nil {
+		t.Fatalf("InsertAll on PostgreSQL error: %v", err)
+	}
+	expected := []string{
+		`SELECT nextval(pg_get_serial_sequence('"tag"','id')) FROM generate_series(1,2)`,
+		`INSERT INTO "tag" ("id","name","uses") OVERRIDING SYSTEM VALUE VALUES ($1,$2,$3),($4,$5,$6)`,
+	}
+	if tags[0].ID != 7 || tags[1].ID != 8 || !reflect.DeepEqual(fake.queries, expected) {
+		t.Errorf("InsertAll on PostgreSQL: expected IDs 7 and 8 from\n%s\nfound %d and %d from\n%s", strings.Join(expected, "\n"), tags[0].ID, tags[1].ID, strings.Join(fake.queries, "\n"))
+	}
+
+	// without a sequence, RETURNING gives no row order, so each row gets
+	// its own statement
+	fake.queries = nil
+	fake.queue([]string{"nextval"}, []driver.Value{nil}, []driver.Value{nil})
+	fake.queue([]string{"id"}, []driver.Value{int64(9)})
+	fake.queue([]string{"id"}, []driver.Value{int64(10)})
+	tags = []*Tag{{Name: "c"}, {Name: "d"}}
+	if err := PostgreSQL.InsertAll(sqlDB, "tag", tags); err != nil {
+		t.Fatalf("InsertAll on PostgreSQL without a sequence error: %v", err)
+	}
+	if tags[0].ID != 9 || tags[1].ID != 10 || len(fake.queries) != 3 {
+		t.Errorf("InsertAll on PostgreSQL without a sequence: expected IDs 9 and 10 from 3 queries, found %d and %d from %q", tags[0].ID, tags[1].ID, fake.queries)
+	}
+	sqlDB.Close()
+
+	// without a way to recover the keys, rows go in one at a time
+	d.UseReturningToGetID = false
+	d.BulkInsertID = BulkInsertIDNone
+	people = []*Person{
+		{Name: "Alice", Email: "alice@alice.com", Opened: when},
+		{Name: "Bob", Email: "bob@bob.com", Opened: when},
+	}
+	if err := d.InsertAll(db, "person", people); err != nil {
+		t.Fatalf("InsertAll error: %v", err)
+	}
+	if people[0].ID != 1 || people[1].ID != 2 {
+		t.Errorf("InsertAll: expected IDs 1 and 2, found %d and %d", people[0].ID, people[1].ID)
+	}
+	db.Exec("delete from person")
+
+	// keys that are not allocated by the database are just inserted
+	members := []Membership{
+		{PersonID: 1, GroupID: 1, Role: "a"},
+		{PersonID: 1, GroupID: 2, Role: "b"},
+	}
+	if err := InsertAll(db, "membership", members); err != nil {
+		t.Errorf("InsertAll with composite key error: %v", err)
+	}
+	var count int
+	db.QueryRow("select count(*) from membership").Scan(&count)
+	if count != 2 {
+		t.Errorf("InsertAll with composite key: expected 2 rows, found %d", count)
+	}
+	db.Exec("delete from membership")
+
+	if err := InsertAll(db, "person", []*Person{}); err != nil {
+		t.Errorf("InsertAll with empty slice error: %v", err)
+	}
+	if err := InsertAll(db, "person", &Person{}); err == nil {
+		t.Errorf("InsertAll with non-slice: expected err, got nil")
+	}
+	if err := InsertAll(db, "person", []*Person{{ID: 5}}); err == nil {
+		t.Errorf("InsertAll with non-zero integer key: expected err, got nil")
+	}
+}
+
+type Tag struct {
+	ID   int64  `meddler:"id,pk"`
+	Name string `meddler:"name"`
+	Uses int    `meddler:"uses"`
+}
+
+// recordingDB records the statements given to ExecContext
+type recordingDB struct {
+	queries []string
+	args    [][]interface{}
+	lastID  int64
+}
+
+func (r *recordingDB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	r.queries = append(r.queries, query)
+	r.args = append(r.args, args)
+	return recordingResult(r.lastID), nil
+}
+
+func (r *recordingDB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return nil, fmt.Errorf("recordingDB does not support queries")
+}
+
+func (r *recordingDB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	panic("recordingDB does not support queries")
+}
+
+type recordingResult int64
+
+func (r recordingResult) LastInsertId() (int64, error) { return int64(r), nil }
+func (r recordingResult) RowsAffected() (int64, error) { return 1, nil }
+
+func TestUpsert(t *testing.T) {
+	once.Do(setup)
+
+	for _, d := range []*Database{SQLite, {Quote: `"`, Placeholder: "?", UseReturningToGetID: true, UpsertSyntax: UpsertOnConflict}} {
+		golang := &Tag{Name: "go", Uses: 1}
+		if err := d.Upsert(db, "tag", golang, "name"); err != nil {
+			t.Fatalf("Upsert error on insert: %v", err)
+		}
+		if golang.ID != 1 {
+			t.Errorf("Upsert on insert: expected ID 1, found %d", golang.ID)
+		}
+		if err := d.Insert(db, "tag", &Tag{Name: "sql", Uses: 1}); err != nil {
+			t.Fatalf("Insert error: %v", err)
+		}
+
+		again := &Tag{Name: "go", Uses: 5}
+		if err := d.Upsert(db, "tag", again, "name"); err != nil {
+			t.Fatalf("Upsert error on update: %v", err)
+		}
+		if again.ID != 1 {
+			t.Errorf("Upsert on update: expected ID 1, found %d", again.ID)
+		}
+		elt := new(Tag)
+		if err := d.Load(db, "tag", elt, 1); err != nil {
+			t.Errorf("Load error: %v", err)
+		}
+		if elt.Uses != 5 {
+			t.Errorf("Upsert on update: expected uses of 5, found %d", elt.Uses)
+		}
+
+		// a primary key that is set is the default conflict target
+		elt.Name = "golang"
+		if err := d.Upsert(db, "tag", elt); err != nil {
+			t.Errorf("Upsert by primary key error: %v", err)
+		}
+		var count int
+		db.QueryRow("select count(*) from tag where name = 'golang'").Scan(&count)
+		if count != 1 {
+			t.Errorf("Upsert by primary key: expected 1 renamed row, found %d", count)
+		}
+
+		if err := d.Upsert(db, "tag", &Tag{Name: "x"}, "nosuchcolumn"); err == nil {
+			t.Errorf("Upsert with unknown conflict column: expected err, got nil")
+		}
+		db.Exec("delete from tag")
+	}
+
+	// MySQL syntax, checked without a server
+	rec := &recordingDB{lastID: 7}
+	tag := &Tag{Name: "go", Uses: 2}
+	if err := MySQL.UpsertContext(context.Background(), rec, "tag", tag); err != nil {
+		t.Fatalf("MySQL Upsert error: %v", err)
+	}
+	expected := "INSERT INTO `tag` (`name`,`uses`) VALUES (?,?) ON DUPLICATE KEY UPDATE `name`=VALUES(`name`),`uses`=VALUES(`uses`),`id`=LAST_INSERT_ID(`id`)"
+	if len(rec.queries) != 1 || rec.queries[0] != expected {
+		t.Errorf("MySQL Upsert:\nexpected %s\nfound    %v", expected, rec.queries)
+	}
+	if tag.ID != 7 {
+		t.Errorf("MySQL Upsert: expected ID 7, found %d", tag.ID)
+	}
+
+	// a row with nothing to overwrite is still touched by a key column
+	type upEvent struct {
+		ID      int64     `meddler:"id,pk"`
+		Created time.Time `meddler:"created,created"`
+	}
+	rec = &recordingDB{lastID: 3}
+	event := &upEvent{}
+	if err := MySQL.UpsertContext(context.Background(), rec, "event", event); err != nil {
+		t.Fatalf("MySQL Upsert with nothing to update error: %v", err)
+	}
+	expected = "INSERT INTO `event` (`created`) VALUES (?) ON DUPLICATE KEY UPDATE `id`=LAST_INSERT_ID(`id`)"
+	if len(rec.queries) != 1 || rec.queries[0] != expected || event.ID != 3 {
+		t.Errorf("MySQL Upsert with nothing to update:\nexpected %s\nfound    %v", expected, rec.queries)
+	}
+	rec = &recordingDB{}
+	if err := MySQL.UpsertContext(context.Background(), rec, "event", &upEvent{ID: 4}); err != nil {
+		t.Fatalf("MySQL Upsert with key and nothing to update error: %v", err)
+	}
+	expected = "INSERT INTO `event` (`id`,`created`) VALUES (?,?) ON DUPLICATE KEY UPDATE `id`=`id`"
+	if len(rec.queries) != 1 || rec.queries[0] != expected {
+		t.Errorf("MySQL Upsert with key and nothing to update:\nexpected %s\nfound    %v", expected, rec.queries)
+	}
+
+	// the primary key is never overwritten, even when it is inserted
+	rec = &recordingDB{}
+	if err := PostgreSQL.UpsertContext(context.Background(), rec, "tag", &Tag{ID: 5, Name: "x"}, "name"); err != nil {
+		t.Fatalf("PostgreSQL Upsert error: %v", err)
+	}
+	expected = `INSERT INTO "tag" ("id","name","uses") VALUES ($1,$2,$3) ON CONFLICT ("name") DO UPDATE SET "uses"=excluded."uses"`
+	if len(rec.queries) != 1 || rec.queries[0] != expected {
+		t.Errorf("PostgreSQL Upsert:\nexpected %s\nfound    %v", expected, rec.queries)
+	}
+}
+
+func TestDelete(t *testing.T) {
+	once.Do(setup)
+	insertAliceBob(t)
+
+	if err := Delete(db, "person", alice); err != nil {
+		t.Errorf("Delete error on Alice: %v", err)
+	}
+	elt := new(Person)
+	if err := Load(db, "person", elt, alice.ID); err != sql.ErrNoRows {
+		t.Errorf("Load after Delete: expected sql.ErrNoRows, got %v", err)
+	}
+	if err := Delete(db, "person", alice); err != ErrNoRowsAffected {
+		t.Errorf("Delete of missing row: expected ErrNoRowsAffected, got %v", err)
+	}
+
+	if err := DeleteByPK(db, "person", elt, bob.ID); err != nil {
+		t.Errorf("DeleteByPK error on Bob: %v", err)
+	}
+	if err := DeleteByPK(db, "person", elt, bob.ID); err != ErrNoRowsAffected {
+		t.Errorf("DeleteByPK of missing row: expected ErrNoRowsAffected, got %v", err)
+	}
+
+	if err := Delete(db, "person", &Person{}); err == nil {
+		t.Errorf("Delete with zero primary key: expected err, got nil")
+	}
+	type personWithoutPK struct {
+		Name string
+	}
+	if err := Delete(db, "person", &personWithoutPK{}); err == nil {
+		t.Errorf("Delete on struct without PK: expected err, got nil")
+	}
+
+	// composite keys
+	for _, m := range []*Membership{{PersonID: 1, GroupID: 1, Role: "a"}, {PersonID: 1, GroupID: 2, Role: "b"}} {
+		if err := Insert(db, "membership", m); err != nil {
+			t.Fatalf("Insert error: %v", err)
+		}
+	}
+	if err := Delete(db, "membership", &Membership{PersonID: 1, GroupID: 1}); err != nil {
+		t.Errorf("Delete with composite key error: %v", err)
+	}
+	if err := DeleteByKey(db, "membership", new(Membership), 1, 2); err != nil {
+		t.Errorf("DeleteByKey error: %v", err)
+	}
+	var count int
+	db.QueryRow("select count(*) from membership").Scan(&count)
+	if count != 0 {
+		t.Errorf("Delete with composite key: expected 0 rows left, found %d", count)
+	}
+}
+
+func TestUpdateColumns(t *testing.T) {
+	once.Do(setup)
+	insertAliceBob(t)
+
+	// change two fields, but only write one of them
+	elt := new(Person)
+	if err := Load(db, "person", elt, 1); err != nil {
+		t.Fatalf("Load error: %v", err)
+	}
+	elt.Name = "Alicia"
+	elt.Email = "alicia@alice.com"
+	if err := UpdateColumns(db, "person", elt, "name"); err != nil {
+		t.Errorf("UpdateColumns error: %v", err)
+	}
+	check := new(Person)
+	if err := Load(db, "person", check, 1); err != nil {
+		t.Fatalf("Load error: %v", err)
+	}
+	if check.Name != "Alicia" || check.Email != "alice@alice.com" {
+		t.Errorf("UpdateColumns: expected Alicia/alice@alice.com, found %s/%s", check.Name, check.Email)
+	}
+
+	// write everything but the email
+	elt.Age = 40
+	if err := UpdateExcept(db, "person", elt, "Email"); err != nil {
+		t.Errorf("UpdateExcept error: %v", err)
+	}
+	if err := Load(db, "person", check, 1); err != nil {
+		t.Fatalf("Load error: %v", err)
+	}
+	if check.Age != 40 || check.Email != "alice@alice.com" {
+		t.Errorf("UpdateExcept: expected 40/alice@alice.com, found %d/%s", check.Age, check.Email)
+	}
+
+	if err := UpdateColumns(db, "person", elt, "nosuchcolumn"); err == nil {
+		t.Errorf("UpdateColumns with unknown column: expected err, got nil")
+	}
+	if err := UpdateExcept(db, "person", elt, "nosuchcolumn"); err == nil {
+		t.Errorf("UpdateExcept with unknown column: expected err, got nil")
+	}
+	if err := UpdateColumns(db, "person", elt, "id"); err == nil {
+		t.Errorf("UpdateColumns with primary key column: expected err, got nil")
+	}
+	if err := UpdateColumns(db, "person", elt, "name", "name"); err == nil {
+		t.Errorf("UpdateColumns with repeated column: expected err, got nil")
+	}
+	if err := UpdateColumns(db, "person", elt); err == nil {
+		t.Errorf("UpdateColumns with no columns: expected err, got nil")
+	}
+	db.Exec("delete from person")
+}
+
+type Account struct {
+	ID      int64  `meddler:"id,pk"`
+	Owner   string `meddler:"owner"`
+	Balance int    `meddler:"balance"`
+	Version int    `meddler:"version,version"`
+}
+
+func TestOptimisticLock(t *testing.T) {
+	once.Do(setup)
+
+	elt := &Account{Owner: "Alice", Balance: 10, Version: 7}
+	if err := Insert(db, "account", elt); err != nil {
+		t.Fatalf("Insert error: %v", err)
+	}
+	if elt.Version != 1 {
+		t.Errorf("Insert: expected version 1, found %d", elt.Version)
+	}
+
+	// two copies of the same row
+	first, second := new(Account), new(Account)
+	if err := Load(db, "account", first, elt.ID); err != nil {
+		t.Fatalf("Load error: %v", err)
+	}
+	if err := Load(db, "account", second, elt.ID); err != nil {
+		t.Fatalf("Load error: %v", err)
+	}
+
+	first.Balance = 20
+	if err := Update(db, "account", first); err != nil {
+		t.Errorf("Update error: %v", err)
+	}
+	if first.Version != 2 {
+		t.Errorf("Update: expected version 2, found %d", first.Version)
+	}
+
+	// the second copy is now out of date
+	second.Balance = 30
+	if err := Update(db, "account", second); err != ErrStaleObject {
+		t.Errorf("Update of stale copy: expected ErrStaleObject, got %v", err)
+	}
+	if second.Version != 1 {
+		t.Errorf("Update of stale copy: expected version to stay 1, found %d", second.Version)
+	}
+	check := new(Account)
+	if err := Load(db, "account", check, elt.ID); err != nil {
+		t.Fatalf("Load error: %v", err)
+	}
+	if check.Balance != 20 || check.Version != 2 {
+		t.Errorf("Update of stale copy: expected 20/2, found %d/%d", check.Balance, check.Version)
+	}
+
+	// partial updates are checked as well
+	first.Owner = "Alicia"
+	if err := UpdateColumns(db, "account", first, "owner"); err != nil {
+		t.Errorf("UpdateColumns error: %v", err)
+	}
+	if err := UpdateColumns(db, "account", second, "owner"); err != ErrStaleObject {
+		t.Errorf("UpdateColumns of stale copy: expected ErrStaleObject, got %v", err)
+	}
+	if err := UpdateColumns(db, "account", first, "version"); err == nil {
+		t.Errorf("UpdateColumns with version column: expected err, got nil")
+	}
+
+	// a struct with nothing but a version still bumps it
+	type accountVersion struct {
+		ID      int64 `meddler:"id,pk"`
+		Version int   `meddler:"version,version"`
+	}
+	bump := &accountVersion{ID: first.ID, Version: first.Version}
+	if err := Update(db, "account", bump); err != nil {
+		t.Errorf("Update of version alone error: %v", err)
+	}
+	if bump.Version != first.Version+1 {
+		t.Errorf("Update of version alone: expected version %d, found %d", first.Version+1, bump.Version)
+	}
+	if err := Update(db, "account", first); err != ErrStaleObject {
+		t.Errorf("Update after version bump: expected ErrStaleObject, got %v", err)
+	}
+
+	// a deleted row is stale too
+	if err := Delete(db, "account", check); err != nil {
+		t.Fatalf("Delete error: %v", err)
+	}
+	if err := Update(db, "account", first); err != ErrStaleObject {
+		t.Errorf("Update of deleted row: expected ErrStaleObject, got %v", err)
+	}
+
+	// upserting an existing row bumps its version
+	elt = &Account{Owner: "Bob", Balance: 5}
+	if err := Insert(db, "account", elt); err != nil {
+		t.Fatalf("Insert error: %v", err)
+	}
+	elt.Balance = 6
+	if err := SQLite.Upsert(db, "account", elt); err != nil {
+		t.Fatalf("Upsert error: %v", err)
+	}
+	if err := Load(db, "account", check, elt.ID); err != nil {
+		t.Fatalf("Load error: %v", err)
+	}
+	if check.Balance != 6 || check.Version != 2 {
+		t.Errorf("Upsert: expected 6/2, found %d/%d", check.Balance, check.Version)
+	}
+
+	// a failed insert does not change the version
+	failed := &Account{Owner: "Carol", Version: 7}
+	if err := Insert(db, "no_such_table", failed); err == nil {
+		t.Errorf("Insert into missing table: expected err, got nil")
+	}
+	if failed.Version != 7 {
+		t.Errorf("failed Insert: expected version to stay 7, found %d", failed.Version)
+	}
+	if err := InsertAll(db, "no_such_table", []*Account{failed}); err == nil {
+		t.Errorf("InsertAll into missing table: expected err, got nil")
+	}
+	if failed.Version != 7 {
+		t.Errorf("failed InsertAll: expected version to stay 7, found %d", failed.Version)
+	}
+
+	type badVersion struct {
+		ID      int64  `meddler:"id,pk"`
+		Version string `meddler:"version,version"`
+	}
+	if _, err := getFields(reflect.TypeOf(&badVersion{})); err == nil {
+		t.Errorf("getFields with string version: expected err, got nil")
+	}
+	db.Exec("delete from account")
+}
+
+type Note struct {
+	ID        int64      `meddler:"id,pk"`
+	Body      string     `meddler:"body"`
+	CreatedAt time.Time  `meddler:"created_at,created,utctime"`
+	UpdatedAt *time.Time `meddler:"updated_at,updated,utctime"`
+}
+
+func TestTimestamps(t *testing.T) {
+	once.Do(setup)
+
+	clock := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	d := *SQLite
+	d.Now = func() time.Time { return clock }
+
+	elt := &Note{Body: "first"}
+	if err := d.Insert(db, "note", elt); err != nil {
+		t.Fatalf("Insert error: %v", err)
+	}
+	if !elt.CreatedAt.Equal(clock) || elt.UpdatedAt == nil || !elt.UpdatedAt.Equal(clock) {
+		t.Errorf("Insert: expected both times to be %v, found %v/%v", clock, elt.CreatedAt, elt.UpdatedAt)
+	}
+	inserted := clock
+
+	// only the updated time changes after that
+	clock = clock.Add(time.Hour)
+	elt.Body = "second"
+	elt.CreatedAt = clock
+	if err := d.UpdateColumns(db, "note", elt, "body"); err != nil {
+		t.Fatalf("UpdateColumns error: %v", err)
+	}
+	check := new(Note)
+	if err := d.Load(db, "note", check, elt.ID); err != nil {
+		t.Fatalf("Load error: %v", err)
+	}
+	if !check.CreatedAt.Equal(inserted) || check.UpdatedAt == nil || !check.UpdatedAt.Equal(clock) {
+		t.Errorf("UpdateColumns: expected %v/%v, found %v/%v", inserted, clock, check.CreatedAt, check.UpdatedAt)
+	}
+
+	clock = clock.Add(time.Hour)
+	check.Body = "third"
+	if err := d.Save(db, "note", check); err != nil {
+		t.Fatalf("Save error: %v", err)
+	}
+	if err := d.Load(db, "note", elt, check.ID); err != nil {
+		t.Fatalf("Load error: %v", err)
+	}
+	if !elt.CreatedAt.Equal(inserted) || elt.UpdatedAt == nil || !elt.UpdatedAt.Equal(clock) {
+		t.Errorf("Save: expected %v/%v, found %v/%v", inserted, clock, elt.CreatedAt, elt.UpdatedAt)
+	}
+
+	// Update never writes the created time
+	elt.CreatedAt = clock.Add(time.Hour)
+	if err := d.Update(db, "note", elt); err != nil {
+		t.Fatalf("Update error: %v", err)
+	}
+	if err := d.Load(db, "note", check, elt.ID); err != nil {
+		t.Fatalf("Load error: %v", err)
+	}
+	if !check.CreatedAt.Equal(inserted) {
+		t.Errorf("Update: expected created time to stay %v, found %v", inserted, check.CreatedAt)
+	}
+
+	// failed writes leave the times alone
+	clock = clock.Add(time.Hour)
+	failed := &Note{Body: "failed"}
+	if err := d.Insert(db, "no_such_table", failed); err == nil {
+		t.Errorf("Insert into missing table: expected err, got nil")
+	}
+	if !failed.CreatedAt.IsZero() || failed.UpdatedAt != nil {
+		t.Errorf("failed Insert: expected no times, found %v/%v", failed.CreatedAt, failed.UpdatedAt)
+	}
+	updated := *elt.UpdatedAt
+	if err := d.Update(db, "no_such_table", elt); err == nil {
+		t.Errorf("Update of missing table: expected err, got nil")
+	}
+	if elt.UpdatedAt == nil || !elt.UpdatedAt.Equal(updated) {
+		t.Errorf("failed Update: expected updated time to stay %v, found %v", updated, elt.UpdatedAt)
+	}
+
+	type badTimestamp struct {
+		ID      int64  `meddler:"id,pk"`
+		Created string `meddler:"created,created"`
+	}
+	if _, err := getFields(reflect.TypeOf(&badTimestamp{})); err == nil {
+		t.Errorf("getFields with string created field: expected err, got nil")
+	}
+	db.Exec("delete from note")
+}
+
+type Document struct {
+	ID        int64      `meddler:"id,pk"`
+	Title     string     `meddler:"title"`
+	DeletedAt *time.Time `meddler:"deleted_at,softdelete,utctime"`
+}
+
+type ArchivedDocument struct {
+	ID       int64  `meddler:"id,pk"`
+	Title    string `meddler:"title"`
+	Archived bool   `meddler:"archived,softdelete"`
+}
+
+func TestSoftDelete(t *testing.T) {
+	once.Do(setup)
+
+	clock := time.Date(2021, 2, 3, 4, 5, 6, 0, time.UTC)
+	d := *SQLite
+	d.Now = func() time.Time { return clock }
+
+	elt := &Document{Title: "report"}
+	if err := d.Insert(db, "document", elt); err != nil {
+		t.Fatalf("Insert error: %v", err)
+	}
+	if err := d.Delete(db, "document", elt); err != nil {
+		t.Fatalf("Delete error: %v", err)
+	}
+	if elt.DeletedAt == nil || !elt.DeletedAt.Equal(clock) {
+		t.Errorf("Delete: expected deleted_at to be %v, found %v", clock, elt.DeletedAt)
+	}
+	var count int
+	db.QueryRow("select count(*) from document").Scan(&count)
+	if count != 1 {
+		t.Errorf("Delete: expected the row to remain, found %d rows", count)
+	}
+	if err := d.Load(db, "document", new(Document), elt.ID); err != sql.ErrNoRows {
+		t.Errorf("Load of deleted row: expected sql.ErrNoRows, got %v", err)
+	}
+	if err := d.Delete(db, "document", elt); err != ErrNoRowsAffected {
+		t.Errorf("Delete of deleted row: expected ErrNoRowsAffected, got %v", err)
+	}
+
+	if err := d.Restore(db, "document", elt); err != nil {
+		t.Fatalf("Restore error: %v", err)
+	}
+	if elt.DeletedAt != nil {
+		t.Errorf("Restore: expected deleted_at to be nil, found %v", elt.DeletedAt)
+	}
+	check := new(Document)
+	if err := d.Load(db, "document", check, elt.ID); err != nil {
+		t.Errorf("Load of restored row error: %v", err)
+	}
+	if err := d.Restore(db, "document", elt); err != ErrNoRowsAffected {
+		t.Errorf("Restore of live row: expected ErrNoRowsAffected, got %v", err)
+	}
+
+	// a bool column works the same way
+	if err := d.DeleteByPK(db, "document", new(ArchivedDocument), elt.ID); err != nil {
+		t.Fatalf("DeleteByPK error: %v", err)
+	}
+	if err := d.Load(db, "document", new(ArchivedDocument), elt.ID); err != sql.ErrNoRows {
+		t.Errorf("Load of archived row: expected sql.ErrNoRows, got %v", err)
+	}
+	archived := &ArchivedDocument{ID: elt.ID, Archived: true}
+	if err := d.Restore(db, "document", archived); err != nil {
+		t.Errorf("Restore error: %v", err)
+	}
+	if archived.Archived {
+		t.Errorf("Restore: expected archived to be false")
+	}
+
+	if err := d.HardDelete(db, "document", elt); err != nil {
+		t.Errorf("HardDelete error: %v", err)
+	}
+	db.QueryRow("select count(*) from document").Scan(&count)
+	if count != 0 {
+		t.Errorf("HardDelete: expected no rows, found %d", count)
+	}
+	if err := d.Restore(db, "person", &Person{ID: 1}); err == nil {
+		t.Errorf("Restore without softdelete field: expected err, got nil")
+	}
+}
@@ -3,6 +3,7 @@ package meddler
 import (
 	"bytes"
 	"compress/gzip"
+	"context"
 	"encoding/gob"
 	"encoding/json"
 	"fmt"
@@ -29,12 +30,68 @@ type Meddler interface {
 	PreWrite(field interface{}) (saveValue interface{}, err error)
 }
 
+// ContextMeddler is an optional interface for meddlers that need the
+// context of the operation they are part of. When a meddler implements it,
+// these methods are always called instead of PreRead, PostRead, and
+// PreWrite, so slow conversions can give up early once the context is
+// cancelled. The functions that do not take a context pass
+// context.Background().
+type ContextMeddler interface {
+	Meddler
+
+	// PreReadContext is the context-aware version of PreRead.
+	PreReadContext(ctx context.Context, fieldAddr interface{}) (scanTarget interface{}, err error)
+
+	// PostReadContext is the context-aware version of PostRead.
+	PostReadContext(ctx context.Context, fieldAddr interface{}, scanTarget interface{}) error
+
+	// PreWriteContext is the context-aware version of PreWrite.
+	PreWriteContext(ctx context.Context, field interface{}) (saveValue interface{}, err error)
+}
+
+// preRead calls the PreRead hook of a meddler, passing the context along if
+// the meddler accepts it.
+func preRead(ctx context.Context, m Meddler, fieldAddr interface{}) (interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if cm, ok := m.(ContextMeddler); ok {
+		return cm.PreReadContext(ctx, fieldAddr)
+	}
+	return m.PreRead(fieldAddr)
+}
+
+// postRead calls the PostRead hook of a meddler, passing the context along if
+// the meddler accepts it.
+func postRead(ctx context.Context, m Meddler, fieldAddr, scanTarget interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if cm, ok := m.(ContextMeddler); ok {
+		return cm.PostReadContext(ctx, fieldAddr, scanTarget)
+	}
+	return m.PostRead(fieldAddr, scanTarget)
+}
+
+// preWrite calls the PreWrite hook of a meddler, passing the context along if
+// the meddler accepts it.
+func preWrite(ctx context.Context, m Meddler, field interface{}) (interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if cm, ok := m.(ContextMeddler); ok {
+		return cm.PreWriteContext(ctx, field)
+	}
+	return m.PreWrite(field)
+}
+
 // Register sets up a meddler type. Meddlers get a chance to meddle with the
 // data being loaded or saved when a field is annotated with the name of the meddler.
 // The registry is global.
 func Register(name string, m Meddler) {
-	if name == "pk" {
-		panic("meddler.Register: pk cannot be used as a meddler name")
+	switch name {
+	case "pk", "version", "created", "updated", "softdelete":
+		panic("meddler.Register: " + name + " cannot be used as a meddler name")
 	}
 	registry[name] = m
 }
@@ -60,17 +117,41 @@ type IdentityMeddler bool
 
 // PreRead is called before a Scan operation for fields that have the IdentityMeddler
 func (elt IdentityMeddler) PreRead(fieldAddr interface{}) (scanTarget interface{}, err error) {
+	if isByteArrayAddr(fieldAddr) {
+		// drivers cannot scan into arrays, so scan into a slice and copy it later
+		return new([]byte), nil
+	}
 	return fieldAddr, nil
 }
 
 // PostRead is called after a Scan operation for fields that have the IdentityMeddler
 func (elt IdentityMeddler) PostRead(fieldAddr, scanTarget interface{}) error {
+	if isByteArrayAddr(fieldAddr) {
+		raw := *scanTarget.(*[]byte)
+		arr := reflect.ValueOf(fieldAddr).Elem()
+		if len(raw) == 0 {
+			arr.Set(reflect.Zero(arr.Type()))
+			return nil
+		}
+		if len(raw) != arr.Len() {
+			return fmt.Errorf("meddler.IdentityMeddler.PostRead: found %d bytes for a %v field", len(raw), arr.Type())
+		}
+		reflect.Copy(arr, reflect.ValueOf(raw))
+	}
 	return nil
 }
 
 // PreWrite is called before an Insert or Update operation for fields that have the IdentityMeddler
 func (elt IdentityMeddler) PreWrite(field interface{}) (saveValue interface{}, err error) {
-	return field, nil
+	return byteArrayArg(field), nil
+}
+
+// isByteArrayAddr reports whether fieldAddr points to a plain byte
+// array such as [16]byte, one that does not implement sql.Scanner.
+func isByteArrayAddr(fieldAddr interface{}) bool {
+	t := reflect.TypeOf(fieldAddr)
+	return t.Kind() == reflect.Ptr && t.Elem().Kind() == reflect.Array &&
+		t.Elem().Elem().Kind() == reflect.Uint8 && !t.Implements(scannerType)
 }
 
 // TimeMeddler provides useful operations on time.Time fields. It can convert the zero time
@@ -1,6 +1,7 @@
 package meddler
 
 import (
+	"context"
 	"testing"
 )
 
@@ -151,3 +152,71 @@ func TestGobMeddler(t *testing.T) {
 		t.Errorf("error wiping item table: %v", err)
 	}
 }
+
+type ctxKey string
+
+// contextMeddler records the value stored in the context it is given
+type contextMeddler struct {
+	IdentityMeddler
+	seen *[]interface{}
+}
+
+func (m contextMeddler) PreReadContext(ctx context.Context, fieldAddr interface{}) (interface{}, error) {
+	*m.seen = append(*m.seen, ctx.Value(ctxKey("op")))
+	return fieldAddr, nil
+}
+
+func (m contextMeddler) PostReadContext(ctx context.Context, fieldAddr, scanTarget interface{}) error {
+	*m.seen = append(*m.seen, ctx.Value(ctxKey("op")))
+	return nil
+}
+
+func (m contextMeddler) PreWriteContext(ctx context.Context, field interface{}) (interface{}, error) {
+	*m.seen = append(*m.seen, ctx.Value(ctxKey("op")))
+	return field, nil
+}
+
+type ItemContext struct {
+	ID    int64  `meddler:"id,pk"`
+	Stuff string `meddler:"stuff,contexttest"`
+	Blob  []byte `meddler:"stuffz"`
+}
+
+func TestContextMeddler(t *testing.T) {
+	once.Do(setup)
+
+	var seen []interface{}
+	Register("contexttest", contextMeddler{seen: &seen})
+
+	ctx := context.WithValue(context.Background(), ctxKey("op"), "insert")
+	elt := &ItemContext{Stuff: "hello", Blob: []byte{}}
+	if err := InsertContext(ctx, db, "item", elt); err != nil {
+		t.Fatalf("InsertContext error: %v", err)
+	}
+
+	ctx = context.WithValue(context.Background(), ctxKey("op"), "load")
+	if err := LoadContext(ctx, db, "item", elt, elt.ID); err != nil {
+		t.Errorf("LoadContext error: %v", err)
+	}
+
+	expected := []interface{}{"insert", "load", "load"}
+	if len(seen) != len(expected) {
+		t.Fatalf("expected %d meddler calls, found %d", len(expected), len(seen))
+	}
+	for i := range expected {
+		if seen[i] != expected[i] {
+			t.Errorf("call %d: expected context value %v, found %v", i, expected[i], seen[i])
+		}
+	}
+
+	// the non-context versions still work with a ContextMeddler
+	if err := Load(db, "item", elt, elt.ID); err != nil {
+		t.Errorf("Load error: %v", err)
+	}
+	if elt.Stuff != "hello" {
+		t.Errorf("expected stuff to be hello, found %s", elt.Stuff)
+	}
+	if _, err := db.Exec("delete from `item`"); err != nil {
+		t.Errorf("error wiping item table: %v", err)
+	}
+}
@@ -0,0 +1,201 @@
+package meddler
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// BindNamed rewrites the :name parameters in a query to the positional
+// placeholders of the database, and returns the query along with the
+// values of the parameters in order. arg is a struct, or a pointer to
+// one, whose columns are looked up the same way as in Insert and written
+// through their meddlers, or a map with string keys. InList values are
+// expanded into a placeholder for each element. Parameters in string
+// literals, quoted identifiers, and comments are left alone, as are
+// PostgreSQL casts such as ::text.
+func (d *Database) BindNamed(query string, arg interface{}) (string, []interface{}, error) {
+	return d.bindNamed(context.Background(), query, arg)
+}
+
+// BindNamed using the Default Database type
+func BindNamed(query string, arg interface{}) (string, []interface{}, error) {
+	return Default.BindNamed(query, arg)
+}
+
+func (d *Database) bindNamed(ctx context.Context, query string, arg interface{}) (string, []interface{}, error) {
+	// find the parameters
+	var b strings.Builder
+	var names []string
+	brackets := d.dialect().BracketQuotes()
+	for i := 0; i < len(query); {
+		end, code := nextToken(query, i, brackets)
+		if code && query[i] == ':' {
+			if strings.HasPrefix(query[i:], "::") {
+				b.WriteString("::")
+				i += 2
+				continue
+			}
+			if n := paramName(query[i+1:]); n > 0 {
+				names = append(names, query[i+1:i+1+n])
+				b.WriteString(d.placeholder(len(names)))
+				i += 1 + n
+				continue
+			}
+		}
+		b.WriteString(query[i:end])
+		i = end
+	}
+
+	args, err := d.namedValues(ctx, names, arg)
+	if err != nil {
+		return "", nil, err
+	}
+	q, args := d.expandIn(b.String(), args)
+	return q, args, nil
+}
+
+// paramName returns the length of the parameter name at the start of s,
+// or zero if there is none.
+func paramName(s string) int {
+	for j := 0; j < len(s); j++ {
+		c := s[j]
+		switch {
+		case c == '_', 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z':
+		case '0' <= c && c <= '9' && j > 0:
+		default:
+			return j
+		}
+	}
+	return len(s)
+}
+
+// namedValues looks up the values of the named parameters in arg.
+func (d *Database) namedValues(ctx context.Context, names []string, arg interface{}) ([]interface{}, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+	v := reflect.ValueOf(arg)
+	if v.Kind() == reflect.Map {
+		if v.Type().Key().Kind() != reflect.String {
+			return nil, &ConfigError{Type: v.Type(), Msg: "meddler.BindNamed called with a map whose keys are not strings"}
+		}
+		var values []interface{}
+		for _, name := range names {
+			value := v.MapIndex(reflect.ValueOf(name).Convert(v.Type().Key()))
+			if !value.IsValid() {
+				return nil, fmt.Errorf("meddler.BindNamed: %w: :%s", ErrParameterNotFound, name)
+			}
+			values = append(values, value.Interface())
+		}
+		return values, nil
+	}
+
+	// the struct must be addressable to be read like a record
+	if v.Kind() == reflect.Struct {
+		ptr := reflect.New(v.Type())
+		ptr.Elem().Set(v)
+		arg = ptr.Interface()
+	} else if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return nil, &ConfigError{Type: reflect.TypeOf(arg), Msg: "meddler.BindNamed called with an argument that is not a struct or a map"}
+	}
+	data, err := getFields(reflect.TypeOf(arg))
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range names {
+		if _, present := data.fields[name]; !present {
+			return nil, fmt.Errorf("meddler.BindNamed: %w: :%s", ErrParameterNotFound, name)
+		}
+	}
+	return d.SomeValuesContext(ctx, arg, names)
+}
+
+// NamedQueryRow performs a query with named parameters, as described in
+// BindNamed, scanning a single row of results into dst.
+func (d *Database) NamedQueryRow(db DB, dst interface{}, query string, arg interface{}) error {
+	return d.NamedQueryRowContext(context.Background(), withContext(db), dst, query, arg)
+}
+
+// NamedQueryRowContext is the context-aware version of NamedQueryRow.
+func (d *Database) NamedQueryRowContext(ctx context.Context, db DBContext, dst interface{}, query string, arg interface{}) error {
+	q, args, err := d.bindNamed(ctx, query, arg)
+	if err != nil {
+		return err
+	}
+	rows, err := db.QueryContext(ctx, q, args...)
+	if err != nil {
+		return &QueryError{Op: "meddler.NamedQueryRow", SQL: q, Err: err}
+	}
+	return d.ScanRowContext(ctx, rows, dst)
+}
+
+// NamedQueryRow using the Default Database type
+func NamedQueryRow(db DB, dst interface{}, query string, arg interface{}) error {
+	return Default.NamedQueryRow(db, dst, query, arg)
+}
+
+// NamedQueryRowContext using the Default Database type
+func NamedQueryRowContext(ctx context.Context, db DBContext, dst interface{}, query string, arg interface{}) error {
+	return Default.NamedQueryRowContext(ctx, db, dst, query, arg)
+}
+
+// NamedQueryAll performs a query with named parameters, as described in
+// BindNamed, scanning all result rows into dst.
+func (d *Database) NamedQueryAll(db DB, dst interface{}, query string, arg interface{}) error {
+	return d.NamedQueryAllContext(context.Background(), withContext(db), dst, query, arg)
+}
+
+// NamedQueryAllContext is the context-aware version of NamedQueryAll.
+func (d *Database) NamedQueryAllContext(ctx context.Context, db DBContext, dst interface{}, query string, arg interface{}) error {
+	q, args, err := d.bindNamed(ctx, query, arg)
+	if err != nil {
+		return err
+	}
+	rows, err := db.QueryContext(ctx, q, args...)
+	if err != nil {
+		return &QueryError{Op: "meddler.NamedQueryAll", SQL: q, Err: err}
+	}
+	return d.ScanAllContext(ctx, rows, dst)
+}
+
+// NamedQueryAll using the Default Database type
+func NamedQueryAll(db DB, dst interface{}, query string, arg interface{}) error {
+	return Default.NamedQueryAll(db, dst, query, arg)
+}
+
+// NamedQueryAllContext using the Default Database type
+func NamedQueryAllContext(ctx context.Context, db DBContext, dst interface{}, query string, arg interface{}) error {
+	return Default.NamedQueryAllContext(ctx, db, dst, query, arg)
+}
+
+// NamedExec runs a statement with named parameters, as described in
+// BindNamed.
+func (d *Database) NamedExec(db DB, query string, arg interface{}) (sql.Result, error) {
+	return d.NamedExecContext(context.Background(), withContext(db), query, arg)
+}
+
+// NamedExecContext is the context-aware version of NamedExec.
+func (d *Database) NamedExecContext(ctx context.Context, db DBContext, query string, arg interface{}) (sql.Result, error) {
+	q, args, err := d.bindNamed(ctx, query, arg)
+	if err != nil {
+		return nil, err
+	}
+	result, err := db.ExecContext(ctx, q, args...)
+	if err != nil {
+		return nil, &QueryError{Op: "meddler.NamedExec", SQL: q, Err: err}
+	}
+	return result, nil
+}
+
+// NamedExec using the Default Database type
+func NamedExec(db DB, query string, arg interface{}) (sql.Result, error) {
+	return Default.NamedExec(db, query, arg)
+}
+
+// NamedExecContext using the Default Database type
+func NamedExecContext(ctx context.Context, db DBContext, query string, arg interface{}) (sql.Result, error) {
+	return Default.NamedExecContext(ctx, db, query, arg)
+}
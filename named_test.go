@@ -0,0 +1,75 @@
+package meddler
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBindNamed(t *testing.T) {
+	// struct fields are found by column and written through their meddlers
+	filter := Person{Name: "Alice", Age: 0, Opened: when}
+	q, args, err := PostgreSQL.BindNamed("SELECT * FROM person WHERE name = :name AND Age = :Age AND opened::date = :opened::date", filter)
+	if err != nil {
+		t.Fatalf("BindNamed error: %v", err)
+	}
+	if expected := "SELECT * FROM person WHERE name = $1 AND Age = $2 AND opened::date = $3::date"; q != expected {
+		t.Errorf("BindNamed: expected %q, found %q", expected, q)
+	}
+	if !reflect.DeepEqual(args, []interface{}{"Alice", nil, when}) {
+		t.Errorf("BindNamed: expected Alice, nil, and the opened time, found %v", args)
+	}
+
+	// maps work too, and each use of a name gets its own placeholder
+	q, args, err = SQLServer.BindNamed("SELECT ':skipped', [odd:name] FROM t WHERE a = :a OR b = :a -- :comment\n", map[string]interface{}{"a": 5})
+	if err != nil {
+		t.Fatalf("BindNamed with map error: %v", err)
+	}
+	if expected := "SELECT ':skipped', [odd:name] FROM t WHERE a = @p1 OR b = @p2 -- :comment\n"; q != expected {
+		t.Errorf("BindNamed with map: expected %q, found %q", expected, q)
+	}
+	if !reflect.DeepEqual(args, []interface{}{5, 5}) {
+		t.Errorf("BindNamed with map: expected 5 and 5, found %v", args)
+	}
+
+	if _, _, err := PostgreSQL.BindNamed("SELECT :missing", map[string]interface{}{}); err == nil {
+		t.Errorf("BindNamed with missing map key: expected err, got nil")
+	}
+	if _, _, err := PostgreSQL.BindNamed("SELECT :missing", alice); err == nil {
+		t.Errorf("BindNamed with missing column: expected err, got nil")
+	}
+	if _, _, err := PostgreSQL.BindNamed("SELECT :a", 5); err == nil {
+		t.Errorf("BindNamed with int argument: expected err, got nil")
+	}
+}
+
+func TestNamedQueries(t *testing.T) {
+	once.Do(setup)
+
+	type tagFilter struct {
+		Name    string `meddler:"name"`
+		MinUses int    `meddler:"min_uses"`
+	}
+	if _, err := NamedExec(db, "insert into tag (name, uses) values (:name, :uses)", &Tag{Name: "named", Uses: 4}); err != nil {
+		t.Fatalf("NamedExec error: %v", err)
+	}
+	if _, err := NamedExec(db, "insert into tag (name, uses) values (:name, :uses)", map[string]interface{}{"name": "other", "uses": 1}); err != nil {
+		t.Fatalf("NamedExec with map error: %v", err)
+	}
+
+	elt := new(Tag)
+	if err := NamedQueryRow(db, elt, "select * from tag where name = :name and uses >= :min_uses", tagFilter{Name: "named", MinUses: 2}); err != nil {
+		t.Fatalf("NamedQueryRow error: %v", err)
+	}
+	if elt.Name != "named" || elt.Uses != 4 {
+		t.Errorf("NamedQueryRow: expected named and 4, found %s and %d", elt.Name, elt.Uses)
+	}
+
+	var all []*Tag
+	if err := NamedQueryAll(db, &all, "select * from tag where uses >= :min_uses order by name", &tagFilter{MinUses: 1}); err != nil {
+		t.Fatalf("NamedQueryAll error: %v", err)
+	}
+	if len(all) != 2 || all[0].Name != "named" || all[1].Name != "other" {
+		t.Errorf("NamedQueryAll: expected named and other, found %v", all)
+	}
+	db.Exec("delete from tag")
+}
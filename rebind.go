@@ -0,0 +1,149 @@
+package meddler
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+)
+
+// Rebind rewrites the ? placeholders in a hand-written query to the
+// placeholder style of the database, numbering them in order, e.g.
+// "WHERE a = ? AND b = ?" becomes "WHERE a = $1 AND b = $2" for
+// PostgreSQL. Question marks in string literals, quoted identifiers, and
+// comments are left alone. Write ?? for a question mark that is not a
+// placeholder, such as the PostgreSQL jsonb operators ?, ?|, and ?&; it is
+// rewritten to a single ?. Databases that use ? placeholders have no such
+// operators, so their queries are returned unchanged.
+func (d *Database) Rebind(query string) string {
+	dialect := d.dialect()
+	if dialect.PlaceholderPrefix() == "" {
+		return query
+	}
+
+	var b strings.Builder
+	n := 0
+	brackets := dialect.BracketQuotes()
+	for i := 0; i < len(query); {
+		end, code := nextToken(query, i, brackets)
+		if code && strings.HasPrefix(query[i:], "??") {
+			b.WriteByte('?')
+			i += 2
+			continue
+		}
+		if code && query[i] == '?' {
+			n++
+			b.WriteString(dialect.Placeholder(n))
+		} else {
+			b.WriteString(query[i:end])
+		}
+		i = end
+	}
+	return b.String()
+}
+
+// Rebind using the Default Database type
+func Rebind(query string) string {
+	return Default.Rebind(query)
+}
+
+// userQuery prepares a hand-written query to be run, expanding InList
+// arguments, and then rebinding its placeholders if AutoRebind is set.
+func (d *Database) userQuery(query string, args []interface{}) (string, []interface{}) {
+	query, args = d.expandIn(query, args)
+	if d.AutoRebind {
+		query = d.Rebind(query)
+	}
+	return query, args
+}
+
+// nextToken finds the end of the token of query that starts at i. A string
+// literal, quoted identifier, or comment is a single token, and code is
+// false for it. Anything else is a single byte of code. Square brackets
+// are treated as quotes if brackets is set.
+func nextToken(query string, i int, brackets bool) (end int, code bool) {
+	rest := query[i:]
+	switch {
+	case rest[0] == '\'' || rest[0] == '"' || rest[0] == '`':
+		return quotedEnd(query, i, rest[0]), false
+	case rest[0] == '[' && brackets:
+		return quotedEnd(query, i, ']'), false
+	case strings.HasPrefix(rest, "--"):
+		if j := strings.IndexByte(rest, '\n'); j >= 0 {
+			return i + j + 1, false
+		}
+		return len(query), false
+	case strings.HasPrefix(rest, "/*"):
+		if j := strings.Index(rest[2:], "*/"); j >= 0 {
+			return i + 2 + j + 2, false
+		}
+		return len(query), false
+	case rest[0] == '$':
+		// PostgreSQL dollar-quoted strings, e.g. $$text$$ or $tag$text$tag$
+		if tag := dollarTag(rest); tag != "" {
+			if j := strings.Index(rest[len(tag):], tag); j >= 0 {
+				return i + len(tag) + j + len(tag), false
+			}
+			return len(query), false
+		}
+	}
+	return i + 1, true
+}
+
+// quotedEnd returns the index just past the quote that closes the quoted
+// token starting at i. A doubled closing quote is an escaped quote.
+func quotedEnd(query string, i int, closer byte) int {
+	for j := i + 1; j < len(query); j++ {
+		if query[j] == closer {
+			if j+1 < len(query) && query[j+1] == closer {
+				j++
+				continue
+			}
+			return j + 1
+		}
+	}
+	return len(query)
+}
+
+// dollarTag returns the opening tag of a dollar-quoted string at the
+// start of s, or "" if there is none. Tags cannot start with a digit,
+// so $1 placeholders are not mistaken for them.
+func dollarTag(s string) string {
+	for j := 1; j < len(s); j++ {
+		c := s[j]
+		switch {
+		case c == '$':
+			return s[:j+1]
+		case c == '_', 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z':
+		case '0' <= c && c <= '9' && j > 1:
+		default:
+			return ""
+		}
+	}
+	return ""
+}
+
+// Exec runs a hand-written statement, expanding InList arguments and
+// rebinding its placeholders first if AutoRebind is set.
+func (d *Database) Exec(db DB, query string, args ...interface{}) (sql.Result, error) {
+	return d.ExecContext(context.Background(), withContext(db), query, args...)
+}
+
+// ExecContext is the context-aware version of Exec.
+func (d *Database) ExecContext(ctx context.Context, db DBContext, query string, args ...interface{}) (sql.Result, error) {
+	query, args = d.userQuery(query, args)
+	result, err := db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return nil, &QueryError{Op: "meddler.Exec", SQL: query, Err: err}
+	}
+	return result, nil
+}
+
+// Exec using the Default Database type
+func Exec(db DB, query string, args ...interface{}) (sql.Result, error) {
+	return Default.Exec(db, query, args...)
+}
+
+// ExecContext using the Default Database type
+func ExecContext(ctx context.Context, db DBContext, query string, args ...interface{}) (sql.Result, error) {
+	return Default.ExecContext(ctx, db, query, args...)
+}
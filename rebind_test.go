@@ -0,0 +1,86 @@
+package meddler
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestRebind(t *testing.T) {
+	tests := []struct {
+		d        *Database
+		query    string
+		expected string
+	}{
+		{PostgreSQL, "SELECT * FROM person WHERE id = ? AND name = ?", "SELECT * FROM person WHERE id = $1 AND name = $2"},
+		{PostgreSQL, "SELECT '?', 'it''s ?', \"odd?\" FROM t WHERE a = ?", "SELECT '?', 'it''s ?', \"odd?\" FROM t WHERE a = $1"},
+		{PostgreSQL, "SELECT a -- why?\nFROM t /* really? */ WHERE b = ?", "SELECT a -- why?\nFROM t /* really? */ WHERE b = $1"},
+		{PostgreSQL, "SELECT $$what?$$, $fn$ok?$fn$ WHERE c = ?", "SELECT $$what?$$, $fn$ok?$fn$ WHERE c = $1"},
+		{PostgreSQL, "SELECT 'unterminated ?", "SELECT 'unterminated ?"},
+		{SQLServer, "SELECT [why?] FROM t WHERE a = ? AND b = ?", "SELECT [why?] FROM t WHERE a = @p1 AND b = @p2"},
+		{Oracle, "UPDATE t SET a = ? WHERE b = ?", "UPDATE t SET a = :1 WHERE b = :2"},
+		{PostgreSQL, "SELECT * FROM t WHERE data ?? ? AND tags ??| ? AND tags ??& ?", "SELECT * FROM t WHERE data ? $1 AND tags ?| $2 AND tags ?& $3"},
+		{PostgreSQL, "SELECT '??' WHERE a = ?", "SELECT '??' WHERE a = $1"},
+		{MySQL, "SELECT * FROM t WHERE a = ?", "SELECT * FROM t WHERE a = ?"},
+		{&Database{Placeholder: "$1"}, "SELECT ?, ?", "SELECT $1, $2"},
+	}
+	for _, test := range tests {
+		if s := test.d.Rebind(test.query); s != test.expected {
+			t.Errorf("Rebind(%q): expected %q, found %q", test.query, test.expected, s)
+		}
+	}
+}
+
+// numberedSQLite uses the ?NNN placeholders that SQLite also accepts
+type numberedSQLite struct {
+	SQLiteDialect
+}
+
+func (numberedSQLite) Placeholder(n int) string {
+	return "?" + strconv.Itoa(n)
+}
+
+func (numberedSQLite) PlaceholderPrefix() string {
+	return "?"
+}
+
+func TestAutoRebind(t *testing.T) {
+	once.Do(setup)
+
+	d := *SQLite
+	d.Dialect = numberedSQLite{}
+	d.AutoRebind = true
+
+	if _, err := d.Exec(db, "insert into tag (name, uses) values (?, ?)", "rebind", 3); err != nil {
+		t.Fatalf("Exec error: %v", err)
+	}
+	elt := new(Tag)
+	if err := d.QueryRow(db, elt, "select * from tag where name = ? and uses = ?", "rebind", 3); err != nil {
+		t.Fatalf("QueryRow error: %v", err)
+	}
+	if elt.Name != "rebind" || elt.Uses != 3 {
+		t.Errorf("QueryRow: expected rebind and 3, found %s and %d", elt.Name, elt.Uses)
+	}
+	var all []*Tag
+	if err := d.QueryAll(db, &all, "select * from tag where name <> '?' and uses > ?", 1); err != nil {
+		t.Fatalf("QueryAll error: %v", err)
+	}
+	if len(all) != 1 {
+		t.Errorf("QueryAll: expected 1 row, found %d", len(all))
+	}
+	db.Exec("delete from tag")
+
+	// the statements that reach the driver are rebound
+	fake := new(fakeDB)
+	sqlDB := fake.open()
+	defer sqlDB.Close()
+	pg := *PostgreSQL
+	pg.AutoRebind = true
+	fake.queue([]string{"id", "name", "uses"})
+	pg.Exec(sqlDB, "delete from tag where id = ?", 1)
+	pg.QueryAll(sqlDB, &all, "select * from tag where uses > ?", 1)
+	expected := []string{"delete from tag where id = $1", "select * from tag where uses > $1"}
+	if !reflect.DeepEqual(fake.queries, expected) {
+		t.Errorf("expected queries %q, found %q", expected, fake.queries)
+	}
+}
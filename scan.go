@@ -1,73 +1,178 @@
 package meddler
 
 import (
+	"context"
 	"database/sql"
+	"database/sql/driver"
+	"errors"
 	"fmt"
 	"log"
 	"reflect"
-	"strconv"
 	"strings"
 	"sync"
+	"time"
 )
 
 // the name of our struct tag
 const tagName = "meddler"
 
 // Database contains database-specific options.
-// MySQL, PostgreSQL, and SQLite are provided for convenience.
+// MySQL, PostgreSQL, SQLite, SQLServer, and Oracle are provided for convenience.
 // Setting Default to any of these lets you use the package-level convenience functions.
+// The Quote, Placeholder, UseReturningToGetID, and UpsertSyntax fields
+// predate Dialect, and are still set in the provided values for code that
+// reads them, but they are ignored whenever Dialect is set, as is RowLocks.
+// To change one of them in a copy of a provided Database, set Dialect to
+// nil as well:
+//
+//	db := *meddler.PostgreSQL
+//	db.Dialect = nil
+//	db.Placeholder = "?"
 type Database struct {
-	Quote               string // the quote character for table and column names
-	Placeholder         string // the placeholder style to use in generated queries
-	UseReturningToGetID bool   // use PostgreSQL-style RETURNING "ID" instead of calling sql.Result.LastInsertID
+	Dialect             Dialect          // the SQL syntax of the database, or nil to use the five fields that follow
+	Quote               string           // the quote character for table and column names, used if Dialect is nil
+	Placeholder         string           // the placeholder style to use in generated queries, used if Dialect is nil
+	UseReturningToGetID bool             // use PostgreSQL-style RETURNING "ID" instead of calling sql.Result.LastInsertID, if Dialect is nil
+	UpsertSyntax        UpsertSyntax     // the clause used by Upsert to update a row that already exists, used if Dialect is nil
+	RowLocks            bool             // lock rows with FOR UPDATE and FOR SHARE, used if Dialect is nil
+	MaxPlaceholders     int              // the most parameters allowed in a single query, or zero for no limit
+	MaxInsertRows       int              // the most rows allowed in a single INSERT statement, or zero for no limit
+	BulkInsertID        BulkInsertID     // how LastInsertID reports the keys allocated by a multi-row INSERT
+	Now                 func() time.Time // the clock used for created and updated fields, or nil for time.Now
+	Classifier          ErrorClassifier  // how ClassifyError recognizes driver errors, or nil to try every built-in classifier
+	StrictIdentifiers   bool             // reject table and column names that are not made of letters, digits, and underscores, with dots allowed in table names
+	AutoRebind          bool             // rewrite ? placeholders in hand-written queries with Rebind
 }
 
+// BulkInsertID describes what sql.Result.LastInsertID reports after an
+// INSERT statement that adds several rows.
+type BulkInsertID int
+
+const (
+	// BulkInsertIDNone means the keys of the other rows cannot be
+	// worked out, so rows that need a key allocated are inserted one
+	// at a time.
+	BulkInsertIDNone BulkInsertID = iota
+
+	// BulkInsertIDFirst means LastInsertID returns the key of the first
+	// row, and the other rows were given the keys that follow it. MySQL
+	// only promises this when innodb_autoinc_lock_mode is 0 or 1; the
+	// default of 2 in MySQL 8 can interleave keys with other inserts, so
+	// the MySQL Database leaves it off.
+	BulkInsertIDFirst
+
+	// BulkInsertIDLast means LastInsertID returns the key of the last
+	// row, and the other rows were given the keys that precede it.
+	BulkInsertIDLast
+)
+
+// UpsertSyntax selects the clause that Upsert adds to an INSERT statement
+// to turn it into an update when the row already exists.
+type UpsertSyntax int
+
+const (
+	// UpsertNone means the database has no upsert support.
+	UpsertNone UpsertSyntax = iota
+
+	// UpsertOnConflict uses ON CONFLICT (...) DO UPDATE SET col=excluded.col,
+	// as supported by PostgreSQL and SQLite.
+	UpsertOnConflict
+
+	// UpsertOnDuplicateKey uses ON DUPLICATE KEY UPDATE col=VALUES(col),
+	// as supported by MySQL.
+	UpsertOnDuplicateKey
+)
+
 // MySQL contains database specific options for executing queries in a MySQL database
 var MySQL = &Database{
+	Dialect:             MySQLDialect{},
 	Quote:               "`",
 	Placeholder:         "?",
 	UseReturningToGetID: false,
+	MaxPlaceholders:     65535,
+	BulkInsertID:        BulkInsertIDNone,
+	UpsertSyntax:        UpsertOnDuplicateKey,
+	RowLocks:            true,
+	Classifier:          ClassifyMySQL,
 }
 
 // PostgreSQL contains database specific options for executing queries in a PostgreSQL database
 var PostgreSQL = &Database{
+	Dialect:             PostgreSQLDialect{},
 	Quote:               `"`,
 	Placeholder:         "$1",
 	UseReturningToGetID: true,
+	MaxPlaceholders:     65535,
+	BulkInsertID:        BulkInsertIDNone,
+	UpsertSyntax:        UpsertOnConflict,
+	RowLocks:            true,
+	Classifier:          ClassifyPostgreSQL,
 }
 
 // SQLite contains database specific options for executing queries in a SQLite database
 var SQLite = &Database{
+	Dialect:             SQLiteDialect{},
 	Quote:               `"`,
 	Placeholder:         "?",
 	UseReturningToGetID: false,
+	MaxPlaceholders:     999,
+	BulkInsertID:        BulkInsertIDLast,
+	UpsertSyntax:        UpsertOnConflict,
+	Classifier:          ClassifySQLite,
+}
+
+// SQLServer contains database specific options for executing queries in a Microsoft SQL Server database
+var SQLServer = &Database{
+	Dialect:         SQLServerDialect{},
+	MaxPlaceholders: 2100,
+	MaxInsertRows:   1000,
+	BulkInsertID:    BulkInsertIDNone,
+	Classifier:      ClassifySQLServer,
+}
+
+// Oracle contains database specific options for executing queries in an Oracle database
+var Oracle = &Database{
+	Dialect:         OracleDialect{},
+	MaxPlaceholders: 65535,
+	BulkInsertID:    BulkInsertIDNone,
+	Classifier:      ClassifyOracle,
 }
 
 // Default contains the default database options (which defaults to MySQL)
 var Default = MySQL
 
 func (d *Database) quoted(s string) string {
-	return d.Quote + s + d.Quote
+	return d.dialect().QuoteIdentifier(s)
 }
 
 func (d *Database) placeholder(n int) string {
-	return strings.Replace(d.Placeholder, "1", strconv.FormatInt(int64(n), 10), 1)
+	return d.dialect().Placeholder(n)
 }
 
 // Debug enables debug mode, where unused columns and struct fields will be logged
 var Debug = true
 
 type structField struct {
-	column     string
-	index      int
-	primaryKey bool
-	meddler    Meddler
+	name        string // the Go field, e.g. "Address.Street"
+	column      string
+	index       []int
+	primaryKey  bool
+	version     bool
+	created     bool
+	updated     bool
+	softDelete  bool
+	sequence    string // the sequence that supplies new primary keys
+	meddler     Meddler
+	meddlerName string
+	rules       []fieldRule
 }
 
 type structData struct {
-	columns []string
-	fields  map[string]*structField
-	pk      string
+	columns    []string
+	fields     map[string]*structField
+	pk         []string
+	version    string
+	softDelete string
 }
 
 // cache reflection data
@@ -85,22 +190,82 @@ func getFields(dstType reflect.Type) (*structData, error) {
 
 	// make sure dst is a non-nil pointer to a struct
 	if dstType.Kind() != reflect.Ptr {
-		return nil, fmt.Errorf("meddler called with non-pointer destination %v", dstType)
+		return nil, &ConfigError{Type: dstType, Msg: "meddler called with non-pointer destination"}
 	}
 	structType := dstType.Elem()
 	if structType.Kind() != reflect.Struct {
-		return nil, fmt.Errorf("meddler called with pointer to non-struct %v", dstType)
+		return nil, &ConfigError{Type: dstType, Msg: "meddler called with pointer to non-struct"}
+	}
+
+	// gather the list of fields in the struct, including embedded ones
+	var candidates []*structField
+	visited := map[reflect.Type]bool{structType: true}
+	if err := collectFields(structType, nil, "", visited, &candidates); err != nil {
+		return nil, err
+	}
+
+	// a field hides deeper fields with the same column name,
+	// the same way encoding/json handles embedded structs
+	byColumn := make(map[string][]*structField)
+	for _, c := range candidates {
+		byColumn[c.column] = append(byColumn[c.column], c)
 	}
 
-	// gather the list of fields in the struct
 	data := new(structData)
 	data.fields = make(map[string]*structField)
 
+	for _, c := range candidates {
+		name := c.column
+		if _, present := data.fields[name]; present {
+			continue
+		}
+		var winner *structField
+		conflict := false
+		for _, other := range byColumn[name] {
+			if winner == nil || len(other.index) < len(winner.index) {
+				winner, conflict = other, false
+			} else if len(other.index) == len(winner.index) {
+				conflict = true
+			}
+		}
+		if conflict {
+			return nil, &ConfigError{Type: structType, Msg: fmt.Sprintf("multiple fields for column %s", name)}
+		}
+
+		winner.name = fieldName(structType, winner.index)
+		if winner.primaryKey {
+			data.pk = append(data.pk, name)
+		}
+		if winner.version {
+			if data.version != "" {
+				return nil, &ConfigError{Type: structType, Field: winner.name, Msg: "marked as the version, but a version column was already found"}
+			}
+			data.version = name
+		}
+		if winner.softDelete {
+			if data.softDelete != "" {
+				return nil, &ConfigError{Type: structType, Field: winner.name, Msg: "marked as softdelete, but a softdelete column was already found"}
+			}
+			data.softDelete = name
+		}
+		data.fields[name] = winner
+		data.columns = append(data.columns, name)
+	}
+
+	fieldsCache[dstType] = data
+	return data, nil
+}
+
+// collectFields gathers the fields of structType, descending into embedded
+// structs and into nested structs tagged with a column prefix.
+// index is the index path leading to structType, and visited holds the
+// struct types on that path so recursive types do not loop forever.
+func collectFields(structType reflect.Type, index []int, prefix string, visited map[reflect.Type]bool, candidates *[]*structField) error {
 	for i := 0; i < structType.NumField(); i++ {
 		f := structType.Field(i)
 
-		// skip non-exported fields
-		if f.PkgPath != "" {
+		// skip non-exported fields, except for embedded structs
+		if f.PkgPath != "" && !f.Anonymous {
 			continue
 		}
 
@@ -112,6 +277,50 @@ func getFields(dstType reflect.Type) (*structData, error) {
 			continue
 		}
 
+		// look for a column prefix for a nested struct
+		nestedPrefix, nested := "", false
+		for j := 1; j < len(tag); j++ {
+			if strings.HasPrefix(tag[j], "prefix=") {
+				nestedPrefix, nested = strings.TrimPrefix(tag[j], "prefix="), true
+			}
+		}
+
+		fieldType := f.Type
+		if fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+		if nested && fieldType.Kind() != reflect.Struct {
+			return &ConfigError{Type: structType, Field: f.Name, Msg: "has a column prefix, but it is not a struct"}
+		}
+
+		// anonymous structs without a column name are flattened
+		if !nested && f.Anonymous && tag[0] == "" && isEmbeddable(fieldType) {
+			nested = true
+		}
+
+		// a non-exported embedded struct can only be used if it
+		// is not a pointer, since it cannot be allocated
+		if f.PkgPath != "" && !(nested && f.Type.Kind() == reflect.Struct) {
+			continue
+		}
+
+		fieldIndex := make([]int, len(index)+1)
+		copy(fieldIndex, index)
+		fieldIndex[len(index)] = i
+
+		if nested {
+			if visited[fieldType] {
+				continue
+			}
+			visited[fieldType] = true
+			err := collectFields(fieldType, fieldIndex, prefix+nestedPrefix, visited, candidates)
+			delete(visited, fieldType)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
 		// default to the field name
 		name := f.Name
 
@@ -122,48 +331,182 @@ func getFields(dstType reflect.Type) (*structData, error) {
 			// use mapper func if field has no explicit tag
 			name = Mapper(f.Name)
 		}
+		name = prefix + name
 
 		// check for a meddler
 		var meddler Meddler = registry["identity"]
+		meddlerName := "identity"
+		primaryKey, version, created, updated, softDelete := false, false, false, false, false
+		var sequence string
+		var rules []fieldRule
 		for j := 1; j < len(tag); j++ {
-			if tag[j] == "pk" {
+			if strings.HasPrefix(tag[j], "sequence=") {
+				sequence = strings.TrimPrefix(tag[j], "sequence=")
+			} else if strings.HasPrefix(tag[j], "validate=") {
+				parsed, err := parseRules(structType, f.Name, strings.TrimPrefix(tag[j], "validate="))
+				if err != nil {
+					return err
+				}
+				rules = append(rules, parsed...)
+			} else if tag[j] == "softdelete" {
+				if f.Type != timeType && f.Type != reflect.PtrTo(timeType) && f.Type.Kind() != reflect.Bool {
+					return &ConfigError{Type: structType, Field: f.Name, Msg: "marked as softdelete, but is not a time.Time, *time.Time, or bool"}
+				}
+				softDelete = true
+			} else if tag[j] == "created" || tag[j] == "updated" {
+				if f.Type != timeType && f.Type != reflect.PtrTo(timeType) {
+					return &ConfigError{Type: structType, Field: f.Name, Msg: fmt.Sprintf("marked as %s, but is not a time.Time or *time.Time", tag[j])}
+				}
+				created = created || tag[j] == "created"
+				updated = updated || tag[j] == "updated"
+			} else if tag[j] == "version" {
+				if !isIntegerKey(f.Type) {
+					return &ConfigError{Type: structType, Field: f.Name, Msg: "marked as the version, but is not an integer type"}
+				}
+				version = true
+			} else if tag[j] == "pk" {
 				if f.Type.Kind() == reflect.Ptr {
-					return nil, fmt.Errorf("meddler found field %s which is marked as the primary key but is a pointer", f.Name)
+					return &ConfigError{Type: structType, Field: f.Name, Msg: "marked as the primary key, but is a pointer"}
 				}
 
-				// make sure it is an int of some kind
-				switch f.Type.Kind() {
-				case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-				case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-				default:
-					return nil, fmt.Errorf("meddler found field %s which is marked as the primary key, but is not an integer type", f.Name)
+				// make sure it is a type that can be used as a key
+				if !isKeyType(f.Type) {
+					return &ConfigError{Type: structType, Field: f.Name, Msg: "marked as the primary key, but is not an integer, string, or byte type"}
 				}
 
-				if data.pk != "" {
-					return nil, fmt.Errorf("meddler found field %s which is marked as the primary key, but a primary key field was already found", f.Name)
-				}
-				data.pk = name
+				primaryKey = true
 			} else if m, present := registry[tag[j]]; present {
-				meddler = m
+				meddler, meddlerName = m, tag[j]
 			} else {
-				return nil, fmt.Errorf("meddler found field %s with meddler %s, but that meddler is not registered", f.Name, tag[j])
+				return &ConfigError{Type: structType, Field: f.Name, Msg: fmt.Sprintf("uses meddler %s", tag[j]), Err: ErrMeddlerNotRegistered}
 			}
 		}
 
-		if _, present := data.fields[name]; present {
-			return nil, fmt.Errorf("meddler found multiple fields for column %s", name)
+		if primaryKey && version {
+			return &ConfigError{Type: structType, Field: f.Name, Msg: "marked as both the primary key and the version"}
 		}
-		data.fields[name] = &structField{
-			column:     name,
-			primaryKey: name == data.pk,
-			index:      i,
-			meddler:    meddler,
+		if sequence != "" && (!primaryKey || !isIntegerKey(f.Type)) {
+			return &ConfigError{Type: structType, Field: f.Name, Msg: "has a sequence, but is not an integer primary key"}
 		}
-		data.columns = append(data.columns, name)
+
+		*candidates = append(*candidates, &structField{
+			column:      name,
+			primaryKey:  primaryKey,
+			version:     version,
+			created:     created,
+			updated:     updated,
+			softDelete:  softDelete,
+			sequence:    sequence,
+			index:       fieldIndex,
+			meddler:     meddler,
+			meddlerName: meddlerName,
+			rules:       rules,
+		})
 	}
 
-	fieldsCache[dstType] = data
-	return data, nil
+	return nil
+}
+
+var (
+	timeType    = reflect.TypeOf(time.Time{})
+	scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+	valuerType  = reflect.TypeOf((*driver.Valuer)(nil)).Elem()
+)
+
+// isEmbeddable reports whether an anonymous field of type t should have its
+// fields flattened into the parent struct. Structs that the database driver
+// handles directly, such as time.Time, are treated as a single column.
+func isEmbeddable(t reflect.Type) bool {
+	if t.Kind() != reflect.Struct || t == timeType {
+		return false
+	}
+	return !t.Implements(valuerType) && !reflect.PtrTo(t).Implements(scannerType)
+}
+
+// isKeyType reports whether a field of type t can be a primary key:
+// an integer, a string, a byte slice or array, or a type that
+// handles its own conversion with driver.Valuer and sql.Scanner.
+func isKeyType(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	case reflect.String:
+		return true
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return true
+		}
+	}
+	return t.Implements(valuerType) || reflect.PtrTo(t).Implements(scannerType)
+}
+
+// isIntegerKey reports whether a primary key field holds an integer,
+// in which case the database is expected to allocate new values.
+func isIntegerKey(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+	return false
+}
+
+// isZeroKey reports whether a primary key value is unset. Empty
+// byte slices are treated the same as nil ones.
+func isZeroKey(v reflect.Value) bool {
+	if v.Kind() == reflect.Slice {
+		return v.Len() == 0
+	}
+	return v.IsZero()
+}
+
+// byteArrayArg converts a plain byte array such as [16]byte into a byte
+// slice, since database drivers do not accept arrays. Other values,
+// including byte arrays that implement driver.Valuer, are returned as is.
+func byteArrayArg(value interface{}) interface{} {
+	v := reflect.ValueOf(value)
+	if v.Kind() != reflect.Array || v.Type().Elem().Kind() != reflect.Uint8 || v.Type().Implements(valuerType) {
+		return value
+	}
+	b := make([]byte, v.Len())
+	reflect.Copy(reflect.ValueOf(b), v)
+	return b
+}
+
+// fieldByIndex returns the struct field with the given index path,
+// allocating any nil embedded struct pointers along the way.
+func fieldByIndex(structVal reflect.Value, index []int) reflect.Value {
+	v := structVal
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v
+}
+
+// fieldValue returns the value of the struct field with the given index
+// path. If the path goes through a nil embedded struct pointer, it returns
+// the zero value of the field instead.
+func fieldValue(structVal reflect.Value, index []int) reflect.Value {
+	v := structVal
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return reflect.Zero(structVal.Type().FieldByIndex(index).Type)
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v
 }
 
 // Columns returns a list of column names for its input struct.
@@ -175,7 +518,7 @@ func (d *Database) Columns(src interface{}, includePk bool) ([]string, error) {
 
 	var names []string
 	for _, elt := range data.columns {
-		if !includePk && elt == data.pk {
+		if !includePk && data.fields[elt].primaryKey {
 			continue
 		}
 		names = append(names, elt)
@@ -213,25 +556,29 @@ func ColumnsQuoted(src interface{}, includePk bool) (string, error) {
 
 // PrimaryKey returns the name and value of the primary key field. The name
 // is the empty string if there is not primary key field marked.
+// It returns an error for a composite primary key; use PrimaryKeys instead.
 func (d *Database) PrimaryKey(src interface{}) (name string, pk int64, err error) {
 	data, err := getFields(reflect.TypeOf(src))
 	if err != nil {
 		return "", 0, err
 	}
 
-	if data.pk == "" {
+	if len(data.pk) == 0 {
 		return "", 0, nil
 	}
+	if len(data.pk) > 1 {
+		return "", 0, fmt.Errorf("meddler.PrimaryKey: %w (%s)", ErrCompositePrimaryKey, strings.Join(data.pk, ","))
+	}
 
-	name = data.pk
-	field := reflect.ValueOf(src).Elem().Field(data.fields[name].index)
+	name = data.pk[0]
+	field := fieldValue(reflect.ValueOf(src).Elem(), data.fields[name].index)
 	switch field.Type().Kind() {
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
 		pk = field.Int()
 	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
 		pk = int64(field.Uint())
 	default:
-		return "", 0, fmt.Errorf("meddler found field %s which is marked as the primary key, but is not an integer type", name)
+		return "", 0, fmt.Errorf("meddler.PrimaryKey: %w: column %s is a %v, so use PrimaryKeyValue", ErrPrimaryKeyNotInteger, name, field.Type())
 	}
 
 	return name, pk, nil
@@ -242,25 +589,52 @@ func PrimaryKey(src interface{}) (name string, pk int64, err error) {
 	return Default.PrimaryKey(src)
 }
 
+// PrimaryKeys returns the names and values of all primary key fields, in
+// the order they appear in the struct. Both lists are empty if there is no
+// primary key field marked.
+func (d *Database) PrimaryKeys(src interface{}) (names []string, values []interface{}, err error) {
+	data, err := getFields(reflect.TypeOf(src))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	structVal := reflect.ValueOf(src).Elem()
+	for _, name := range data.pk {
+		names = append(names, name)
+		values = append(values, fieldValue(structVal, data.fields[name].index).Interface())
+	}
+
+	return names, values, nil
+}
+
+// PrimaryKeys using the Default Database type
+func PrimaryKeys(src interface{}) (names []string, values []interface{}, err error) {
+	return Default.PrimaryKeys(src)
+}
+
 // SetPrimaryKey sets the primary key field to the given int value.
+// It returns an error for a composite primary key.
 func (d *Database) SetPrimaryKey(src interface{}, pk int64) error {
 	data, err := getFields(reflect.TypeOf(src))
 	if err != nil {
 		return err
 	}
 
-	if data.pk == "" {
-		return fmt.Errorf("meddler.SetPrimaryKey: no primary key field found")
+	if len(data.pk) == 0 {
+		return fmt.Errorf("meddler.SetPrimaryKey: %w", ErrNoPrimaryKey)
+	}
+	if len(data.pk) > 1 {
+		return fmt.Errorf("meddler.SetPrimaryKey: %w (%s)", ErrCompositePrimaryKey, strings.Join(data.pk, ","))
 	}
 
-	field := reflect.ValueOf(src).Elem().Field(data.fields[data.pk].index)
+	field := fieldByIndex(reflect.ValueOf(src).Elem(), data.fields[data.pk[0]].index)
 	switch field.Type().Kind() {
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
 		field.SetInt(pk)
 	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
 		field.SetUint(uint64(pk))
 	default:
-		return fmt.Errorf("meddler found field %s which is marked as the primary key, but is not an integer type", data.pk)
+		return fmt.Errorf("meddler.SetPrimaryKey: %w: column %s is a %v, so use SetPrimaryKeyValue", ErrPrimaryKeyNotInteger, data.pk[0], field.Type())
 	}
 
 	return nil
@@ -271,16 +645,94 @@ func SetPrimaryKey(src interface{}, pk int64) error {
 	return Default.SetPrimaryKey(src, pk)
 }
 
+// PrimaryKeyValue returns the name and value of the primary key field,
+// which may be of any supported key type. The name is the empty string if
+// there is not primary key field marked. It returns an error for a
+// composite primary key; use PrimaryKeys instead.
+func (d *Database) PrimaryKeyValue(src interface{}) (name string, pk interface{}, err error) {
+	data, err := getFields(reflect.TypeOf(src))
+	if err != nil {
+		return "", nil, err
+	}
+
+	if len(data.pk) == 0 {
+		return "", nil, nil
+	}
+	if len(data.pk) > 1 {
+		return "", nil, fmt.Errorf("meddler.PrimaryKeyValue: %w (%s)", ErrCompositePrimaryKey, strings.Join(data.pk, ","))
+	}
+
+	name = data.pk[0]
+	return name, fieldValue(reflect.ValueOf(src).Elem(), data.fields[name].index).Interface(), nil
+}
+
+// PrimaryKeyValue using the Default Database type
+func PrimaryKeyValue(src interface{}) (name string, pk interface{}, err error) {
+	return Default.PrimaryKeyValue(src)
+}
+
+// SetPrimaryKeyValue sets the primary key field to the given value, which
+// must be assignable to the field. Integer values are converted to the
+// integer type of the field, and other values are handed to the Scan
+// method of fields that implement sql.Scanner.
+// It returns an error for a composite primary key.
+func (d *Database) SetPrimaryKeyValue(src interface{}, pk interface{}) error {
+	data, err := getFields(reflect.TypeOf(src))
+	if err != nil {
+		return err
+	}
+
+	if len(data.pk) == 0 {
+		return fmt.Errorf("meddler.SetPrimaryKeyValue: %w", ErrNoPrimaryKey)
+	}
+	if len(data.pk) > 1 {
+		return fmt.Errorf("meddler.SetPrimaryKeyValue: %w (%s)", ErrCompositePrimaryKey, strings.Join(data.pk, ","))
+	}
+
+	field := fieldByIndex(reflect.ValueOf(src).Elem(), data.fields[data.pk[0]].index)
+	val := reflect.ValueOf(pk)
+	switch {
+	case pk == nil:
+		return &FieldError{Op: "meddler.SetPrimaryKeyValue", Field: data.fields[data.pk[0]].name, Column: data.pk[0], Err: errors.New("cannot store nil in the primary key")}
+	case val.Type().AssignableTo(field.Type()):
+		field.Set(val)
+	case isIntegerKey(field.Type()) && isIntegerKey(val.Type()):
+		if val.CanInt() {
+			return d.SetPrimaryKey(src, val.Int())
+		}
+		return d.SetPrimaryKey(src, int64(val.Uint()))
+	case field.Addr().Type().Implements(scannerType):
+		if err := field.Addr().Interface().(sql.Scanner).Scan(pk); err != nil {
+			return fmt.Errorf("meddler.SetPrimaryKeyValue: error scanning key: %w", err)
+		}
+	default:
+		return &FieldError{Op: "meddler.SetPrimaryKeyValue", Field: data.fields[data.pk[0]].name, Column: data.pk[0], Err: fmt.Errorf("cannot store a %T in a %v field", pk, field.Type())}
+	}
+
+	return nil
+}
+
+// SetPrimaryKeyValue using the Default Database type
+func SetPrimaryKeyValue(src interface{}, pk interface{}) error {
+	return Default.SetPrimaryKeyValue(src, pk)
+}
+
 // Values returns a list of PreWrite processed values suitable for
 // use in an INSERT or UPDATE query. If includePk is false, the primary
 // key field is omitted. The columns used are the same ones (in the same
 // order) as returned by Columns.
 func (d *Database) Values(src interface{}, includePk bool) ([]interface{}, error) {
+	return d.ValuesContext(context.Background(), src, includePk)
+}
+
+// ValuesContext is the context-aware version of Values. The context is
+// passed on to meddlers that implement ContextMeddler.
+func (d *Database) ValuesContext(ctx context.Context, src interface{}, includePk bool) ([]interface{}, error) {
 	columns, err := d.Columns(src, includePk)
 	if err != nil {
 		return nil, err
 	}
-	return d.SomeValues(src, columns)
+	return d.SomeValuesContext(ctx, src, columns)
 }
 
 // Values using the Default Database type
@@ -288,10 +740,21 @@ func Values(src interface{}, includePk bool) ([]interface{}, error) {
 	return Default.Values(src, includePk)
 }
 
+// ValuesContext using the Default Database type
+func ValuesContext(ctx context.Context, src interface{}, includePk bool) ([]interface{}, error) {
+	return Default.ValuesContext(ctx, src, includePk)
+}
+
 // SomeValues returns a list of PreWrite processed values suitable for
 // use in an INSERT or UPDATE query. The columns used are the same ones (in
 // the same order) as specified in the columns argument.
 func (d *Database) SomeValues(src interface{}, columns []string) ([]interface{}, error) {
+	return d.SomeValuesContext(context.Background(), src, columns)
+}
+
+// SomeValuesContext is the context-aware version of SomeValues. The context
+// is passed on to meddlers that implement ContextMeddler.
+func (d *Database) SomeValuesContext(ctx context.Context, src interface{}, columns []string) ([]interface{}, error) {
 	data, err := getFields(reflect.TypeOf(src))
 	if err != nil {
 		return nil, err
@@ -311,9 +774,9 @@ func (d *Database) SomeValues(src interface{}, columns []string) ([]interface{},
 			continue
 		}
 
-		saveVal, err := field.meddler.PreWrite(structVal.Field(field.index).Interface())
+		saveVal, err := preWrite(ctx, field.meddler, fieldValue(structVal, field.index).Interface())
 		if err != nil {
-			return nil, fmt.Errorf("meddler.SomeValues: PreWrite error on column [%s]: %v", name, err)
+			return nil, fieldError("PreWrite", field, err)
 		}
 		values = append(values, saveVal)
 	}
@@ -326,6 +789,11 @@ func SomeValues(src interface{}, columns []string) ([]interface{}, error) {
 	return Default.SomeValues(src, columns)
 }
 
+// SomeValuesContext using the Default Database type
+func SomeValuesContext(ctx context.Context, src interface{}, columns []string) ([]interface{}, error) {
+	return Default.SomeValuesContext(ctx, src, columns)
+}
+
 // Placeholders returns a list of placeholders suitable for an INSERT or UPDATE query.
 // If includePk is false, the primary key field is omitted.
 func (d *Database) Placeholders(src interface{}, includePk bool) ([]string, error) {
@@ -336,7 +804,7 @@ func (d *Database) Placeholders(src interface{}, includePk bool) ([]string, erro
 
 	var placeholders []string
 	for _, name := range data.columns {
-		if !includePk && name == data.pk {
+		if !includePk && data.fields[name].primaryKey {
 			continue
 		}
 		ph := d.placeholder(len(placeholders) + 1)
@@ -369,32 +837,44 @@ func PlaceholdersString(src interface{}, includePk bool) (string, error) {
 }
 
 // scan a single row of data into a struct.
-func (d *Database) scanRow(data *structData, rows *sql.Rows, dst interface{}, columns []string) error {
+func (d *Database) scanRow(ctx context.Context, data *structData, rows *sql.Rows, dst interface{}, columns []string) error {
 	// check if there is data waiting
 	if !rows.Next() {
 		if err := rows.Err(); err != nil {
-			return err
+			return &QueryError{Op: "meddler.Scan", Err: err}
 		}
 		return sql.ErrNoRows
 	}
 
 	// get a list of targets
-	targets, err := d.Targets(dst, columns)
+	targets, err := d.TargetsContext(ctx, dst, columns)
 	if err != nil {
 		return err
 	}
 
 	// perform the scan
 	if err := rows.Scan(targets...); err != nil {
-		return err
+		return &ScanError{Op: "meddler.Scan", Err: err}
 	}
 
 	// post-process and copy the target values into the struct
-	if err := d.WriteTargets(dst, columns, targets); err != nil {
+	if err := d.WriteTargetsContext(ctx, dst, columns, targets); err != nil {
+		return err
+	}
+
+	// remember what was loaded for change tracking
+	if err := d.snapshot(ctx, dst, columns); err != nil {
 		return err
 	}
 
-	return rows.Err()
+	if err := afterScan(ctx, dst); err != nil {
+		return err
+	}
+
+	if err := rows.Err(); err != nil {
+		return &QueryError{Op: "meddler.Scan", Err: err}
+	}
+	return nil
 }
 
 // Targets returns a list of values suitable for handing to a
@@ -402,6 +882,12 @@ func (d *Database) scanRow(data *structData, rows *sql.Rows, dst interface{}, co
 // the Scan is performed, the same values should be handed to
 // WriteTargets to finalize the values and record them in the struct.
 func (d *Database) Targets(dst interface{}, columns []string) ([]interface{}, error) {
+	return d.TargetsContext(context.Background(), dst, columns)
+}
+
+// TargetsContext is the context-aware version of Targets. The context is
+// passed on to meddlers that implement ContextMeddler.
+func (d *Database) TargetsContext(ctx context.Context, dst interface{}, columns []string) ([]interface{}, error) {
 	data, err := getFields(reflect.TypeOf(dst))
 	if err != nil {
 		return nil, err
@@ -412,10 +898,10 @@ func (d *Database) Targets(dst interface{}, columns []string) ([]interface{}, er
 	var targets []interface{}
 	for _, name := range columns {
 		if field, present := data.fields[name]; present {
-			fieldAddr := structVal.Field(field.index).Addr().Interface()
-			scanTarget, err := field.meddler.PreRead(fieldAddr)
+			fieldAddr := fieldByIndex(structVal, field.index).Addr().Interface()
+			scanTarget, err := preRead(ctx, field.meddler, fieldAddr)
 			if err != nil {
-				return nil, fmt.Errorf("meddler.Targets: PreRead error on column %s: %v", name, err)
+				return nil, fieldError("PreRead", field, err)
 			}
 			targets = append(targets, scanTarget)
 		} else {
@@ -436,13 +922,23 @@ func Targets(dst interface{}, columns []string) ([]interface{}, error) {
 	return Default.Targets(dst, columns)
 }
 
+// TargetsContext using the Default Database type
+func TargetsContext(ctx context.Context, dst interface{}, columns []string) ([]interface{}, error) {
+	return Default.TargetsContext(ctx, dst, columns)
+}
+
 // WriteTargets post-processes values with meddlers after a Scan from the
 // sql package has been performed. The list of targets is normally produced
 // by Targets.
 func (d *Database) WriteTargets(dst interface{}, columns []string, targets []interface{}) error {
+	return d.WriteTargetsContext(context.Background(), dst, columns, targets)
+}
+
+// WriteTargetsContext is the context-aware version of WriteTargets. The
+// context is passed on to meddlers that implement ContextMeddler.
+func (d *Database) WriteTargetsContext(ctx context.Context, dst interface{}, columns []string, targets []interface{}) error {
 	if len(columns) != len(targets) {
-		return fmt.Errorf("meddler.WriteTargets: mismatch in number of columns (%d) and targets (%d)",
-			len(columns), len(targets))
+		return &ConfigError{Type: reflect.TypeOf(dst), Msg: fmt.Sprintf("meddler.WriteTargets called with %d columns but %d targets", len(columns), len(targets))}
 	}
 
 	data, err := getFields(reflect.TypeOf(dst))
@@ -453,10 +949,10 @@ func (d *Database) WriteTargets(dst interface{}, columns []string, targets []int
 
 	for i, name := range columns {
 		if field, present := data.fields[name]; present {
-			fieldAddr := structVal.Field(field.index).Addr().Interface()
-			err := field.meddler.PostRead(fieldAddr, targets[i])
+			fieldAddr := fieldByIndex(structVal, field.index).Addr().Interface()
+			err := postRead(ctx, field.meddler, fieldAddr, targets[i])
 			if err != nil {
-				return fmt.Errorf("meddler.WriteTargets: PostRead error on column [%s]: %v", name, err)
+				return fieldError("PostRead", field, err)
 			}
 		} else {
 			// not destination, so throw this away
@@ -474,10 +970,21 @@ func WriteTargets(dst interface{}, columns []string, targets []interface{}) erro
 	return Default.WriteTargets(dst, columns, targets)
 }
 
+// WriteTargetsContext using the Default Database type
+func WriteTargetsContext(ctx context.Context, dst interface{}, columns []string, targets []interface{}) error {
+	return Default.WriteTargetsContext(ctx, dst, columns, targets)
+}
+
 // Scan scans a single sql result row into a struct.
 // It leaves rows ready to be scanned again for the next row.
 // Returns sql.ErrNoRows if there is no data to read.
 func (d *Database) Scan(rows *sql.Rows, dst interface{}) error {
+	return d.ScanContext(context.Background(), rows, dst)
+}
+
+// ScanContext is the context-aware version of Scan. The context is passed
+// on to meddlers that implement ContextMeddler.
+func (d *Database) ScanContext(ctx context.Context, rows *sql.Rows, dst interface{}) error {
 	// get the list of struct fields
 	data, err := getFields(reflect.TypeOf(dst))
 	if err != nil {
@@ -489,8 +996,9 @@ func (d *Database) Scan(rows *sql.Rows, dst interface{}) error {
 	if err != nil {
 		return err
 	}
+	columns = d.resultColumns(data, columns)
 
-	return d.scanRow(data, rows, dst, columns)
+	return d.scanRow(ctx, data, rows, dst, columns)
 }
 
 // Scan using the Default Database type
@@ -498,18 +1006,32 @@ func Scan(rows *sql.Rows, dst interface{}) error {
 	return Default.Scan(rows, dst)
 }
 
+// ScanContext using the Default Database type
+func ScanContext(ctx context.Context, rows *sql.Rows, dst interface{}) error {
+	return Default.ScanContext(ctx, rows, dst)
+}
+
 // ScanRow scans a single sql result row into a struct.
 // It reads exactly one result row and closes rows when finished.
 // Returns sql.ErrNoRows if there is no result row.
 func (d *Database) ScanRow(rows *sql.Rows, dst interface{}) error {
+	return d.ScanRowContext(context.Background(), rows, dst)
+}
+
+// ScanRowContext is the context-aware version of ScanRow. The context is
+// passed on to meddlers that implement ContextMeddler.
+func (d *Database) ScanRowContext(ctx context.Context, rows *sql.Rows, dst interface{}) error {
 	// make sure we always close rows, even if there is a scan error
 	defer rows.Close()
 
-	if err := d.Scan(rows, dst); err != nil {
+	if err := d.ScanContext(ctx, rows, dst); err != nil {
 		return err
 	}
 
-	return rows.Close()
+	if err := rows.Close(); err != nil {
+		return &QueryError{Op: "meddler.ScanRow", Err: err}
+	}
+	return nil
 }
 
 // ScanRow using the Default Database type
@@ -517,32 +1039,53 @@ func ScanRow(rows *sql.Rows, dst interface{}) error {
 	return Default.ScanRow(rows, dst)
 }
 
+// ScanRowContext using the Default Database type
+func ScanRowContext(ctx context.Context, rows *sql.Rows, dst interface{}) error {
+	return Default.ScanRowContext(ctx, rows, dst)
+}
+
 // ScanAll scans all sql result rows into a slice of structs.
 // It reads all rows and closes rows when finished.
 // dst should be a pointer to a slice of the appropriate type.
 // The new results will be appended to any existing data in dst.
 func (d *Database) ScanAll(rows *sql.Rows, dst interface{}) error {
+	return d.ScanAllContext(context.Background(), rows, dst)
+}
+
+// ScanAllContext is the context-aware version of ScanAll. The context is
+// passed on to meddlers that implement ContextMeddler.
+func (d *Database) ScanAllContext(ctx context.Context, rows *sql.Rows, dst interface{}) error {
 	// make sure we always close rows
 	defer rows.Close()
 
 	// make sure dst is an appropriate type
 	dstVal := reflect.ValueOf(dst)
 	if dstVal.Kind() != reflect.Ptr || dstVal.IsNil() {
-		return fmt.Errorf("ScanAll called with non-pointer destination: %T", dst)
+		return &ConfigError{Type: reflect.TypeOf(dst), Msg: "ScanAll called with non-pointer destination"}
 	}
 	sliceVal := dstVal.Elem()
 	if sliceVal.Kind() != reflect.Slice {
-		return fmt.Errorf("ScanAll called with pointer to non-slice: %T", dst)
+		return &ConfigError{Type: reflect.TypeOf(dst), Msg: "ScanAll called with pointer to non-slice"}
 	}
 	ptrType := sliceVal.Type().Elem()
 	if ptrType.Kind() != reflect.Ptr {
-		return fmt.Errorf("ScanAll expects element to be pointers, found %T", dst)
+		return &ConfigError{Type: reflect.TypeOf(dst), Msg: "ScanAll expects element to be pointers"}
 	}
 	eltType := ptrType.Elem()
 	if eltType.Kind() != reflect.Struct {
-		return fmt.Errorf("ScanAll expects element to be pointers to structs, found %T", dst)
+		return &ConfigError{Type: reflect.TypeOf(dst), Msg: "ScanAll expects element to be pointers to structs"}
 	}
 
+	return d.scanRows(ctx, rows, ptrType, -1, func(eltVal reflect.Value) {
+		sliceVal.Set(reflect.Append(sliceVal, eltVal))
+	})
+}
+
+// scanRows scans up to limit rows into newly-allocated values of the
+// struct type that ptrType points to, handing each one to add. A negative
+// limit reads every row. It does not close rows. ScanAll and the generic
+// functions both gather their results this way.
+func (d *Database) scanRows(ctx context.Context, rows *sql.Rows, ptrType reflect.Type, limit int, add func(eltVal reflect.Value)) error {
 	// get the list of struct fields
 	data, err := getFields(ptrType)
 	if err != nil {
@@ -554,27 +1097,29 @@ func (d *Database) ScanAll(rows *sql.Rows, dst interface{}) error {
 	if err != nil {
 		return err
 	}
+	columns = d.resultColumns(data, columns)
 
 	// gather the results
-	for {
-		// create a new element
-		eltVal := reflect.New(eltType)
-		elt := eltVal.Interface()
-
-		// scan it
-		if err := d.scanRow(data, rows, elt, columns); err != nil {
+	for n := 0; limit < 0 || n < limit; n++ {
+		// create a new element and scan it
+		eltVal := reflect.New(ptrType.Elem())
+		if err := d.scanRow(ctx, data, rows, eltVal.Interface(), columns); err != nil {
 			if err == sql.ErrNoRows {
 				return nil
 			}
 			return err
 		}
-
-		// add to the result slice
-		sliceVal.Set(reflect.Append(sliceVal, eltVal))
+		add(eltVal)
 	}
+	return nil
 }
 
 // ScanAll using the Default Database type
 func ScanAll(rows *sql.Rows, dst interface{}) error {
 	return Default.ScanAll(rows, dst)
 }
+
+// ScanAllContext using the Default Database type
+func ScanAllContext(ctx context.Context, rows *sql.Rows, dst interface{}) error {
+	return Default.ScanAllContext(ctx, rows, dst)
+}
@@ -78,6 +78,66 @@ const schema3 = `create table null_item (
 	nullbool integer null
 )`
 
+const schema4 = `create table contact (
+	id integer primary key,
+	name text not null,
+	created datetime not null,
+	modified datetime,
+	created_by text,
+	addr_street text,
+	addr_city text
+)`
+
+const schema5 = `create table membership (
+	person_id integer not null,
+	group_id integer not null,
+	role text not null,
+	primary key (person_id, group_id)
+)`
+
+const schema6 = `create table token (
+	id text primary key,
+	label text not null
+)`
+
+const schema7 = `create table device (
+	id blob primary key,
+	label text not null
+)`
+
+const schema8 = `create table tag (
+	id integer primary key,
+	name text not null unique,
+	uses integer not null
+)`
+
+const schema9 = `create table account (
+	id integer primary key,
+	owner text not null,
+	balance integer not null,
+	version integer not null
+)`
+
+const schema10 = `create table note (
+	id integer primary key,
+	body text not null,
+	created_at timestamp not null,
+	updated_at timestamp
+)`
+
+const schema11 = `create table document (
+	id integer primary key,
+	title text not null,
+	deleted_at timestamp,
+	archived boolean not null default 0
+)`
+
+const schema12 = `create table stock (
+	id integer primary key,
+	tag_id integer references tag(id),
+	qty integer not null constraint qty_positive check (qty >= 0)
+)`
+
 var aliceHeight int = 65
 var alice = &Person{
 	Name:      "Alice",
@@ -115,6 +175,33 @@ func setup() {
 	if _, err = db.Exec(schema3); err != nil {
 		panic("error creating null_item table: " + err.Error())
 	}
+	if _, err = db.Exec(schema4); err != nil {
+		panic("error creating contact table: " + err.Error())
+	}
+	if _, err = db.Exec(schema5); err != nil {
+		panic("error creating membership table: " + err.Error())
+	}
+	if _, err = db.Exec(schema6); err != nil {
+		panic("error creating token table: " + err.Error())
+	}
+	if _, err = db.Exec(schema7); err != nil {
+		panic("error creating device table: " + err.Error())
+	}
+	if _, err = db.Exec(schema8); err != nil {
+		panic("error creating tag table: " + err.Error())
+	}
+	if _, err = db.Exec(schema9); err != nil {
+		panic("error creating account table: " + err.Error())
+	}
+	if _, err = db.Exec(schema10); err != nil {
+		panic("error creating note table: " + err.Error())
+	}
+	if _, err = db.Exec(schema11); err != nil {
+		panic("error creating document table: " + err.Error())
+	}
+	if _, err = db.Exec(schema12); err != nil {
+		panic("error creating stock table: " + err.Error())
+	}
 
 }
 
@@ -129,7 +216,10 @@ func structFieldEqual(t *testing.T, elt *structField, ref *structField) {
 	if elt.primaryKey != ref.primaryKey {
 		t.Errorf("Column %s primaryKey found as %v", ref.column, elt.primaryKey)
 	}
-	if elt.index != ref.index {
+	if elt.version != ref.version {
+		t.Errorf("Column %s version found as %v", ref.column, elt.version)
+	}
+	if !reflect.DeepEqual(elt.index, ref.index) {
 		t.Errorf("Column %s index found as %v", ref.column, elt.index)
 	}
 	if elt.meddler != ref.meddler {
@@ -148,14 +238,14 @@ func TestGetFields(t *testing.T) {
 	if len(data.fields) != 8 || len(data.columns) != 8 {
 		t.Errorf("Found %d/%d fields, expected 8", len(data.fields), len(data.columns))
 	}
-	structFieldEqual(t, data.fields[data.columns[0]], &structField{"id", 0, true, registry["identity"]})
-	structFieldEqual(t, data.fields[data.columns[1]], &structField{"name", 1, false, registry["identity"]})
-	structFieldEqual(t, data.fields[data.columns[2]], &structField{"Email", 3, false, registry["identity"]})
-	structFieldEqual(t, data.fields[data.columns[3]], &structField{"Age", 5, false, registry["zeroisnull"]})
-	structFieldEqual(t, data.fields[data.columns[4]], &structField{"opened", 6, false, registry["utctime"]})
-	structFieldEqual(t, data.fields[data.columns[5]], &structField{"closed", 7, false, registry["utctimez"]})
-	structFieldEqual(t, data.fields[data.columns[6]], &structField{"updated", 8, false, registry["localtime"]})
-	structFieldEqual(t, data.fields[data.columns[7]], &structField{"height", 9, false, registry["identity"]})
+	structFieldEqual(t, data.fields[data.columns[0]], &structField{column: "id", index: []int{0}, primaryKey: true, meddler: registry["identity"]})
+	structFieldEqual(t, data.fields[data.columns[1]], &structField{column: "name", index: []int{1}, primaryKey: false, meddler: registry["identity"]})
+	structFieldEqual(t, data.fields[data.columns[2]], &structField{column: "Email", index: []int{3}, primaryKey: false, meddler: registry["identity"]})
+	structFieldEqual(t, data.fields[data.columns[3]], &structField{column: "Age", index: []int{5}, primaryKey: false, meddler: registry["zeroisnull"]})
+	structFieldEqual(t, data.fields[data.columns[4]], &structField{column: "opened", index: []int{6}, primaryKey: false, meddler: registry["utctime"]})
+	structFieldEqual(t, data.fields[data.columns[5]], &structField{column: "closed", index: []int{7}, primaryKey: false, meddler: registry["utctimez"]})
+	structFieldEqual(t, data.fields[data.columns[6]], &structField{column: "updated", index: []int{8}, primaryKey: false, meddler: registry["localtime"]})
+	structFieldEqual(t, data.fields[data.columns[7]], &structField{column: "height", index: []int{9}, primaryKey: false, meddler: registry["identity"]})
 
 	// test with non-pointer
 	if _, err := getFields(reflect.TypeOf(*alice)); err == nil {
@@ -205,6 +295,152 @@ func TestGetFields(t *testing.T) {
 
 }
 
+type Timestamps struct {
+	Created  time.Time `meddler:"created,utctime"`
+	Modified time.Time `meddler:"modified,utctimez"`
+}
+
+type AuditInfo struct {
+	CreatedBy string `meddler:"created_by"`
+}
+
+type Address struct {
+	Street string `meddler:"street"`
+	City   string `meddler:"city"`
+}
+
+type Contact struct {
+	ID   int64  `meddler:"id,pk"`
+	Name string `meddler:"name"`
+	Timestamps
+	*AuditInfo
+	Addr Address `meddler:"addr,prefix=addr_"`
+}
+
+func TestGetFieldsEmbedded(t *testing.T) {
+	data, err := getFields(reflect.TypeOf((*Contact)(nil)))
+	if err != nil {
+		t.Fatalf("Error in getFields: %v", err)
+	}
+
+	expected := []*structField{
+		{column: "id", index: []int{0}, primaryKey: true, meddler: registry["identity"]},
+		{column: "name", index: []int{1}, meddler: registry["identity"]},
+		{column: "created", index: []int{2, 0}, meddler: registry["utctime"]},
+		{column: "modified", index: []int{2, 1}, meddler: registry["utctimez"]},
+		{column: "created_by", index: []int{3, 0}, meddler: registry["identity"]},
+		{column: "addr_street", index: []int{4, 0}, meddler: registry["identity"]},
+		{column: "addr_city", index: []int{4, 1}, meddler: registry["identity"]},
+	}
+	if len(data.columns) != len(expected) {
+		t.Fatalf("Found %d columns, expected %d: %v", len(data.columns), len(expected), data.columns)
+	}
+	for i, ref := range expected {
+		if data.columns[i] != ref.column {
+			t.Errorf("Expected column %s at position %d, found %s", ref.column, i, data.columns[i])
+		}
+		structFieldEqual(t, data.fields[ref.column], ref)
+	}
+
+	// a shallower field hides a deeper one
+	type shadowed struct {
+		ID int64 `meddler:"id,pk"`
+		Timestamps
+		Created time.Time `meddler:"created"`
+	}
+	data, err = getFields(reflect.TypeOf((*shadowed)(nil)))
+	if err != nil {
+		t.Fatalf("Error in getFields: %v", err)
+	}
+	structFieldEqual(t, data.fields["created"], &structField{column: "created", index: []int{2}, meddler: registry["identity"]})
+
+	// two fields at the same depth conflict
+	type conflict struct {
+		Timestamps
+		Other struct {
+			Created time.Time `meddler:"created"`
+		} `meddler:",prefix="`
+	}
+	if _, err := getFields(reflect.TypeOf((*conflict)(nil))); err == nil {
+		t.Errorf("calling getFields with conflicting embedded columns should return err, got nil")
+	}
+
+	// a shallower field resolves a conflict between deeper ones, even
+	// when it comes after them
+	type inner struct {
+		Created time.Time `meddler:"created"`
+	}
+	type resolved struct {
+		Timestamps
+		inner
+		Created time.Time `meddler:"created"`
+	}
+	data, err = getFields(reflect.TypeOf((*resolved)(nil)))
+	if err != nil {
+		t.Fatalf("Error in getFields: %v", err)
+	}
+	structFieldEqual(t, data.fields["created"], &structField{column: "created", index: []int{2}, meddler: registry["identity"]})
+
+	// a prefix only makes sense on a struct
+	type badPrefix struct {
+		Name string `meddler:"name,prefix=x_"`
+	}
+	if _, err := getFields(reflect.TypeOf((*badPrefix)(nil))); err == nil {
+		t.Errorf("calling getFields with prefix on non-struct should return err, got nil")
+	}
+
+	// embedded time.Time is a single column
+	type embeddedTime struct {
+		time.Time `meddler:",utctime"`
+	}
+	data, err = getFields(reflect.TypeOf((*embeddedTime)(nil)))
+	if err != nil {
+		t.Fatalf("Error in getFields: %v", err)
+	}
+	if len(data.columns) != 1 || data.columns[0] != "Time" {
+		t.Errorf("Expected embedded time.Time to give column Time, found %v", data.columns)
+	}
+}
+
+func TestEmbeddedRoundTrip(t *testing.T) {
+	once.Do(setup)
+
+	src := &Contact{
+		Name:       "Alice",
+		Timestamps: Timestamps{Created: when},
+		AuditInfo:  &AuditInfo{CreatedBy: "bob"},
+		Addr:       Address{Street: "1 Main St", City: "Springfield"},
+	}
+	if err := Insert(db, "contact", src); err != nil {
+		t.Fatalf("Insert error: %v", err)
+	}
+
+	// nil embedded pointers are written as zero values
+	other := &Contact{Name: "Carol", Timestamps: Timestamps{Created: when}}
+	if err := Insert(db, "contact", other); err != nil {
+		t.Fatalf("Insert error with nil embedded pointer: %v", err)
+	}
+	if other.AuditInfo != nil {
+		t.Errorf("Insert allocated a nil embedded pointer")
+	}
+
+	// and allocated when scanned into
+	dst := new(Contact)
+	if err := Load(db, "contact", dst, src.ID); err != nil {
+		t.Fatalf("Load error: %v", err)
+	}
+	if dst.Name != "Alice" || !dst.Created.Equal(when) || !dst.Modified.IsZero() {
+		t.Errorf("Load found wrong values: %+v", dst)
+	}
+	if dst.AuditInfo == nil || dst.CreatedBy != "bob" {
+		t.Errorf("Load did not fill embedded pointer: %+v", dst.AuditInfo)
+	}
+	if dst.Addr.Street != "1 Main St" || dst.Addr.City != "Springfield" {
+		t.Errorf("Load did not fill prefixed struct: %+v", dst.Addr)
+	}
+	db.Exec("delete from contact")
+}
+
 func personEqual(t *testing.T, elt *Person, ref *Person) {
 	if elt == nil {
 		t.Errorf("Person %s is nil", ref.Name)
@@ -354,6 +590,103 @@ func TestPrimaryKey(t *testing.T) {
 	}
 }
 
+func TestPrimaryKeys(t *testing.T) {
+	p := &Person{ID: 56}
+	names, values, err := PrimaryKeys(p)
+	if err != nil {
+		t.Errorf("Error getting PrimaryKeys: %v", err)
+	}
+	if len(names) != 1 || names[0] != "id" {
+		t.Errorf("Expected pk names to be [id], found %v", names)
+	}
+	if len(values) != 1 || values[0] != int64(56) {
+		t.Errorf("Expected pk values to be [56], found %v", values)
+	}
+
+	m := &Membership{PersonID: 3, GroupID: 7}
+	names, values, err = PrimaryKeys(m)
+	if err != nil {
+		t.Errorf("Error getting PrimaryKeys: %v", err)
+	}
+	if len(names) != 2 || names[0] != "person_id" || names[1] != "group_id" {
+		t.Errorf("Expected pk names to be [person_id group_id], found %v", names)
+	}
+	if len(values) != 2 || values[0] != int64(3) || values[1] != int64(7) {
+		t.Errorf("Expected pk values to be [3 7], found %v", values)
+	}
+
+	// the single-key functions refuse composite keys
+	if _, _, err := PrimaryKey(m); err == nil {
+		t.Errorf("PrimaryKey on composite key: expected err, got nil")
+	}
+	if err := SetPrimaryKey(m, 1); err == nil {
+		t.Errorf("SetPrimaryKey on composite key: expected err, got nil")
+	}
+
+	columns, err := Columns(m, false)
+	if err != nil {
+		t.Errorf("Error getting Columns: %v", err)
+	}
+	if len(columns) != 1 || columns[0] != "role" {
+		t.Errorf("Expected non-pk columns to be [role], found %v", columns)
+	}
+}
+
+func TestPrimaryKeyValue(t *testing.T) {
+	tok := &Token{ID: "abc"}
+	name, val, err := PrimaryKeyValue(tok)
+	if err != nil {
+		t.Errorf("Error getting PrimaryKeyValue: %v", err)
+	}
+	if name != "id" || val != "abc" {
+		t.Errorf("Expected pk id=abc, found %s=%v", name, val)
+	}
+	if _, _, err := PrimaryKey(tok); err == nil {
+		t.Errorf("PrimaryKey on string key: expected err, got nil")
+	}
+
+	p := &Person{ID: 12}
+	name, val, err = PrimaryKeyValue(p)
+	if err != nil {
+		t.Errorf("Error getting PrimaryKeyValue: %v", err)
+	}
+	if name != "id" || val != int64(12) {
+		t.Errorf("Expected pk id=12, found %s=%v", name, val)
+	}
+
+	if err := SetPrimaryKeyValue(tok, "xyz"); err != nil {
+		t.Errorf("Error in SetPrimaryKeyValue: %v", err)
+	}
+	if tok.ID != "xyz" {
+		t.Errorf("Expected id to be xyz, found %s", tok.ID)
+	}
+	if err := SetPrimaryKeyValue(tok, 5); err == nil {
+		t.Errorf("SetPrimaryKeyValue with int on string key: expected err, got nil")
+	}
+	if err := SetPrimaryKeyValue(p, uint32(7)); err != nil {
+		t.Errorf("Error in SetPrimaryKeyValue: %v", err)
+	}
+	if p.ID != 7 {
+		t.Errorf("Expected id to be 7, found %d", p.ID)
+	}
+
+	dev := new(Device)
+	if err := SetPrimaryKeyValue(dev, [16]byte{1, 2, 3}); err != nil {
+		t.Errorf("Error in SetPrimaryKeyValue: %v", err)
+	}
+	if dev.ID[2] != 3 {
+		t.Errorf("Expected id to be set, found %v", dev.ID)
+	}
+
+	// keys of other types are still rejected
+	type floatPK struct {
+		ID float64 `meddler:"id,pk"`
+	}
+	if _, err := getFields(reflect.TypeOf((*floatPK)(nil))); err == nil {
+		t.Errorf("calling getFields with float as primary key should return err, got nil")
+	}
+}
+
 func TestSetPrimaryKey(t *testing.T) {
 	p := new(Person)
 	err := SetPrimaryKey(p, 14)
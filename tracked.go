@@ -0,0 +1,210 @@
+package meddler
+
+import (
+	"context"
+	"database/sql/driver"
+	"reflect"
+	"time"
+)
+
+// Tracked can be embedded in a struct to turn on change tracking for it.
+// Whenever the struct is scanned from a query, inserted, or updated,
+// meddler records the values of its columns, and UpdateChanged uses them
+// to write only the columns that have changed since. For example:
+//
+//	type Person struct {
+//	    meddler.Tracked
+//	    ID   int64  `meddler:"id,pk"`
+//	    Name string `meddler:"name"`
+//	}
+//
+// Tracked must be embedded by value, and it adds no columns. A struct
+// that embeds it can be copied; each copy keeps its own record from then
+// on.
+type Tracked struct {
+	snapshot map[string]interface{}
+}
+
+// tracker is implemented by every struct that embeds Tracked.
+type tracker interface {
+	tracked() *Tracked
+}
+
+func (t *Tracked) tracked() *Tracked {
+	return t
+}
+
+// ResetTracking forgets the recorded values, so the next UpdateChanged
+// writes every column.
+func (t *Tracked) ResetTracking() {
+	t.snapshot = nil
+}
+
+// snapshot records the current values of the named columns of src if it
+// embeds Tracked. Columns that are not in the struct are ignored.
+func (d *Database) snapshot(ctx context.Context, src interface{}, columns []string) error {
+	tr, ok := src.(tracker)
+	if !ok {
+		return nil
+	}
+	t := tr.tracked()
+	if t == nil {
+		return nil
+	}
+
+	data, err := getFields(reflect.TypeOf(src))
+	if err != nil {
+		return err
+	}
+	var present []string
+	for _, name := range columns {
+		if _, ok := data.fields[name]; ok {
+			present = append(present, name)
+		}
+	}
+	values, err := d.SomeValuesContext(ctx, src, present)
+	if err != nil {
+		return err
+	}
+
+	// copies of the struct share the map, so replace it instead of
+	// changing it
+	snapshot := make(map[string]interface{}, len(t.snapshot)+len(present))
+	for name, value := range t.snapshot {
+		snapshot[name] = value
+	}
+	for i, name := range present {
+		snapshot[name] = snapshotValue(values[i])
+	}
+	t.snapshot = snapshot
+
+	return nil
+}
+
+// snapshotAll records the values of every column of src if it embeds Tracked.
+func (d *Database) snapshotAll(ctx context.Context, src interface{}) error {
+	if _, ok := src.(tracker); !ok {
+		return nil
+	}
+	columns, err := d.Columns(src, true)
+	if err != nil {
+		return err
+	}
+	return d.snapshot(ctx, src, columns)
+}
+
+// snapshotValue makes a copy of a PreWrite value that will not change when
+// the struct does, following pointers and copying byte slices.
+func snapshotValue(value interface{}) interface{} {
+	v, err := driver.DefaultParameterConverter.ConvertValue(value)
+	if err != nil {
+		return value
+	}
+	if b, ok := v.([]byte); ok {
+		return append([]byte(nil), b...)
+	}
+	return v
+}
+
+// snapshotEqual compares two values made by snapshotValue.
+func snapshotEqual(a, b interface{}) bool {
+	if ta, ok := a.(time.Time); ok {
+		tb, ok := b.(time.Time)
+		return ok && ta.Equal(tb)
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// ChangedColumns returns the non-primary key columns of src whose values
+// differ from the ones recorded when it was last scanned, inserted, or
+// updated. src must embed Tracked. If nothing has been recorded yet, every
+// column is returned. A version column is managed by meddler, so it is
+// never reported.
+func (d *Database) ChangedColumns(src interface{}) ([]string, error) {
+	return d.ChangedColumnsContext(context.Background(), src)
+}
+
+// ChangedColumnsContext is the context-aware version of ChangedColumns.
+func (d *Database) ChangedColumnsContext(ctx context.Context, src interface{}) ([]string, error) {
+	tr, ok := src.(tracker)
+	if !ok || tr.tracked() == nil {
+		return nil, &ConfigError{Type: reflect.TypeOf(src), Msg: "meddler.ChangedColumns called without an embedded meddler.Tracked"}
+	}
+	t := tr.tracked()
+
+	data, err := getFields(reflect.TypeOf(src))
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, name := range data.columns {
+		if field := data.fields[name]; !field.primaryKey && !field.version {
+			names = append(names, name)
+		}
+	}
+	values, err := d.SomeValuesContext(ctx, src, names)
+	if err != nil {
+		return nil, err
+	}
+
+	var changed []string
+	for i, name := range names {
+		old, present := t.snapshot[name]
+		if !present || !snapshotEqual(old, snapshotValue(values[i])) {
+			changed = append(changed, name)
+		}
+	}
+
+	return changed, nil
+}
+
+// ChangedColumns using the Default Database type
+func ChangedColumns(src interface{}) ([]string, error) {
+	return Default.ChangedColumns(src)
+}
+
+// ChangedColumnsContext using the Default Database type
+func ChangedColumnsContext(ctx context.Context, src interface{}) ([]string, error) {
+	return Default.ChangedColumnsContext(ctx, src)
+}
+
+// UpdateChanged performs an UPDATE query for the given record, like Update,
+// but only writes the columns returned by ChangedColumns. The columns are
+// compared after the BeforeUpdate hook has run, so changes it makes are
+// written too. If no column has changed, no query is run at all, and the
+// AfterUpdate hook is not called. src must embed Tracked.
+func (d *Database) UpdateChanged(db DB, table string, src interface{}) error {
+	return d.UpdateChangedContext(context.Background(), withContext(db), table, src)
+}
+
+// UpdateChangedContext is the context-aware version of UpdateChanged.
+func (d *Database) UpdateChangedContext(ctx context.Context, db DBContext, table string, src interface{}) error {
+	if _, err := d.ChangedColumnsContext(ctx, src); err != nil {
+		return err
+	}
+	if err := d.checkIdentifiers("meddler.Update", table, src); err != nil {
+		return err
+	}
+	if err := beforeUpdate(ctx, db, src); err != nil {
+		return err
+	}
+	changed, err := d.ChangedColumnsContext(ctx, src)
+	if err != nil {
+		return err
+	}
+	if len(changed) == 0 {
+		return nil
+	}
+
+	return d.updateRow(ctx, db, table, src, changed)
+}
+
+// UpdateChanged using the Default Database type
+func UpdateChanged(db DB, table string, src interface{}) error {
+	return Default.UpdateChanged(db, table, src)
+}
+
+// UpdateChangedContext using the Default Database type
+func UpdateChangedContext(ctx context.Context, db DBContext, table string, src interface{}) error {
+	return Default.UpdateChangedContext(ctx, db, table, src)
+}
@@ -0,0 +1,115 @@
+package meddler
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+)
+
+type TrackedPerson struct {
+	Tracked
+	ID      int64      `meddler:"id,pk"`
+	Name    string     `meddler:"name"`
+	Email   string     `meddler:"Email"`
+	Age     int        `meddler:"Age,zeroisnull"`
+	Opened  time.Time  `meddler:"opened,utctime"`
+	Updated *time.Time `meddler:"updated,localtime"`
+	Height  *int       `meddler:"height"`
+}
+
+// countingDB counts the statements run through ExecContext
+type countingDB struct {
+	DBContext
+	execs []string
+}
+
+func (c *countingDB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	c.execs = append(c.execs, query)
+	return c.DBContext.ExecContext(ctx, query, args...)
+}
+
+func TestUpdateChanged(t *testing.T) {
+	once.Do(setup)
+	insertAliceBob(t)
+	ctx := context.Background()
+	cdb := &countingDB{DBContext: db}
+
+	elt := new(TrackedPerson)
+	if err := LoadContext(ctx, cdb, "person", elt, 1); err != nil {
+		t.Fatalf("Load error: %v", err)
+	}
+
+	// nothing changed, so no query
+	if err := UpdateChangedContext(ctx, cdb, "person", elt); err != nil {
+		t.Errorf("UpdateChanged error: %v", err)
+	}
+	if len(cdb.execs) != 0 {
+		t.Errorf("UpdateChanged with no changes ran %v", cdb.execs)
+	}
+
+	// changes through pointers are noticed
+	*elt.Height = 70
+	elt.Name = "Alicia"
+	changed, err := ChangedColumns(elt)
+	if err != nil {
+		t.Errorf("ChangedColumns error: %v", err)
+	}
+	if len(changed) != 2 || changed[0] != "name" || changed[1] != "height" {
+		t.Errorf("ChangedColumns: expected [name height], found %v", changed)
+	}
+	if err := UpdateChangedContext(ctx, cdb, "person", elt); err != nil {
+		t.Errorf("UpdateChanged error: %v", err)
+	}
+	expected := "UPDATE `person` SET `name`=?,`height`=? WHERE `id`=?"
+	if len(cdb.execs) != 1 || cdb.execs[0] != expected {
+		t.Errorf("UpdateChanged:\nexpected %s\nfound    %v", expected, cdb.execs)
+	}
+
+	// the update is the new baseline
+	if err := UpdateChangedContext(ctx, cdb, "person", elt); err != nil {
+		t.Errorf("UpdateChanged error: %v", err)
+	}
+	if len(cdb.execs) != 1 {
+		t.Errorf("UpdateChanged after update ran %v", cdb.execs[1:])
+	}
+
+	check := new(Person)
+	if err := Load(db, "person", check, 1); err != nil {
+		t.Fatalf("Load error: %v", err)
+	}
+	if check.Name != "Alicia" || *check.Height != 70 {
+		t.Errorf("UpdateChanged: expected Alicia/70, found %s/%d", check.Name, *check.Height)
+	}
+
+	// an inserted record is tracked too
+	carol := &TrackedPerson{Name: "Carol", Email: "carol@carol.com", Opened: when}
+	if err := Insert(db, "person", carol); err != nil {
+		t.Fatalf("Insert error: %v", err)
+	}
+	if changed, _ := ChangedColumns(carol); len(changed) != 0 {
+		t.Errorf("ChangedColumns after Insert: expected none, found %v", changed)
+	}
+
+	// a copy keeps its own record once either one is saved
+	copied := *carol
+	copied.Name = "Caroline"
+	if err := UpdateChanged(db, "person", &copied); err != nil {
+		t.Fatalf("UpdateChanged of copy error: %v", err)
+	}
+	carol.Name = "Caroline"
+	if changed, _ := ChangedColumns(carol); len(changed) != 1 || changed[0] != "name" {
+		t.Errorf("ChangedColumns after a copy was saved: expected [name], found %v", changed)
+	}
+
+	// without a snapshot, everything is written
+	carol.ResetTracking()
+	if changed, _ := ChangedColumns(carol); len(changed) != 6 {
+		t.Errorf("ChangedColumns after ResetTracking: expected 6 columns, found %v", changed)
+	}
+
+	if _, err := ChangedColumns(new(Person)); err == nil {
+		t.Errorf("ChangedColumns on untracked struct: expected err, got nil")
+	}
+	db.Exec("delete from person")
+}
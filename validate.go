@@ -0,0 +1,202 @@
+package meddler
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Validator is implemented by structs that check themselves before they
+// are written by Insert, Update, Save, and their variants. It runs after
+// the validate rules in the struct tags. To report problems with
+// particular columns, return a *ValidationError; any other error is
+// reported as a failure of the struct as a whole.
+type Validator interface {
+	Validate() error
+}
+
+// ValidationRule checks a field value for the validate tag option. It is
+// given the value of the field and the argument of the rule, e.g. "255"
+// for max=255 or "" for required, and returns an error describing the
+// problem if the value is not valid.
+type ValidationRule func(field interface{}, arg string) error
+
+// ValidationError is returned by Insert, Update, and their variants when
+// a record fails validation. It lists every failure that was found, so
+// no query is run until all of them are fixed.
+type ValidationError struct {
+	Failures []ValidationFailure
+}
+
+// ValidationFailure describes a single failed check.
+type ValidationFailure struct {
+	Column string // the column that failed, or empty for the record as a whole
+	Rule   string // the rule that failed, e.g. "max", or empty for a Validator
+	Err    error  // the problem reported by the rule or Validator
+}
+
+func (err *ValidationError) Error() string {
+	var parts []string
+	for _, f := range err.Failures {
+		switch {
+		case f.Column == "":
+			parts = append(parts, f.Err.Error())
+		case f.Rule == "":
+			parts = append(parts, fmt.Sprintf("%s: %v", f.Column, f.Err))
+		default:
+			parts = append(parts, fmt.Sprintf("%s: %s: %v", f.Column, f.Rule, f.Err))
+		}
+	}
+	return "meddler: validation failed: " + strings.Join(parts, "; ")
+}
+
+// Columns returns the names of the columns that failed, in order,
+// with each column listed once.
+func (err *ValidationError) Columns() []string {
+	var columns []string
+	seen := make(map[string]bool)
+	for _, f := range err.Failures {
+		if f.Column != "" && !seen[f.Column] {
+			seen[f.Column] = true
+			columns = append(columns, f.Column)
+		}
+	}
+	return columns
+}
+
+// validationRules is the registry of rules for the validate tag option.
+var validationRules = make(map[string]ValidationRule)
+
+// RegisterRule sets up a validation rule that can be named in the validate
+// tag option of a struct field, e.g. `meddler:"code,validate=required|upper"`.
+// The registry is global.
+func RegisterRule(name string, rule ValidationRule) {
+	if name == "" || strings.ContainsAny(name, "|=,") {
+		panic("meddler.RegisterRule: invalid rule name " + strconv.Quote(name))
+	}
+	validationRules[name] = rule
+}
+
+func init() {
+	RegisterRule("required", requiredRule)
+	RegisterRule("min", func(field interface{}, arg string) error { return sizeRule(field, arg, false) })
+	RegisterRule("max", func(field interface{}, arg string) error { return sizeRule(field, arg, true) })
+}
+
+// fieldRule is a rule parsed from the validate tag option of a field.
+type fieldRule struct {
+	name  string
+	arg   string
+	check ValidationRule
+}
+
+// parseRules parses the rules of a validate tag option, e.g.
+// "required|max=255", making sure each one has been registered.
+func parseRules(structType reflect.Type, fieldName, spec string) ([]fieldRule, error) {
+	var list []fieldRule
+	for _, elt := range strings.Split(spec, "|") {
+		name, arg, _ := strings.Cut(elt, "=")
+		check, present := validationRules[name]
+		if !present {
+			return nil, &ConfigError{Type: structType, Field: fieldName, Msg: fmt.Sprintf("uses validation rule %s", name), Err: ErrRuleNotRegistered}
+		}
+		list = append(list, fieldRule{name: name, arg: arg, check: check})
+	}
+	return list, nil
+}
+
+// validate checks the named columns of src against the rules in its tags,
+// followed by its Validate method if it has one. It returns a
+// *ValidationError if anything failed.
+func validate(src interface{}, columns []string) error {
+	data, err := getFields(reflect.TypeOf(src))
+	if err != nil {
+		return err
+	}
+	structVal := reflect.ValueOf(src).Elem()
+
+	verr := new(ValidationError)
+	for _, name := range columns {
+		field, present := data.fields[name]
+		if !present || len(field.rules) == 0 {
+			continue
+		}
+		value := fieldValue(structVal, field.index).Interface()
+		for _, rule := range field.rules {
+			if err := rule.check(value, rule.arg); err != nil {
+				verr.Failures = append(verr.Failures, ValidationFailure{Column: name, Rule: rule.name, Err: err})
+			}
+		}
+	}
+
+	if v, ok := src.(Validator); ok {
+		if err := v.Validate(); err != nil {
+			var other *ValidationError
+			if errors.As(err, &other) {
+				verr.Failures = append(verr.Failures, other.Failures...)
+			} else {
+				verr.Failures = append(verr.Failures, ValidationFailure{Err: err})
+			}
+		}
+	}
+
+	if len(verr.Failures) > 0 {
+		return verr
+	}
+	return nil
+}
+
+// requiredRule fails for the zero value of any type,
+// including nil pointers and empty strings.
+func requiredRule(field interface{}, arg string) error {
+	v := reflect.ValueOf(field)
+	if !v.IsValid() || v.IsZero() {
+		return errors.New("a value is required")
+	}
+	return nil
+}
+
+// sizeRule compares the length of a string (in characters), slice, or map,
+// or the value of a number, with the limit given in arg. Nil pointers pass,
+// so they can be combined with required.
+func sizeRule(field interface{}, arg string, max bool) error {
+	limit, err := strconv.ParseFloat(arg, 64)
+	if err != nil {
+		return fmt.Errorf("invalid limit %q", arg)
+	}
+	v := reflect.ValueOf(field)
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+
+	var n float64
+	what := "length"
+	switch v.Kind() {
+	case reflect.String:
+		n = float64(utf8.RuneCountInString(v.String()))
+	case reflect.Slice, reflect.Map, reflect.Array:
+		n = float64(v.Len())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, what = float64(v.Int()), "value"
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, what = float64(v.Uint()), "value"
+	case reflect.Float32, reflect.Float64:
+		n, what = v.Float(), "value"
+	default:
+		return fmt.Errorf("cannot check the size of a %v", v.Type())
+	}
+
+	if max && n > limit {
+		return fmt.Errorf("%s must be at most %s", what, arg)
+	}
+	if !max && n < limit {
+		return fmt.Errorf("%s must be at least %s", what, arg)
+	}
+	return nil
+}
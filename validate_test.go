@@ -0,0 +1,180 @@
+package meddler
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type ValidatedTag struct {
+	ID   int64  `meddler:"id,pk"`
+	Name string `meddler:"name,validate=required|max=8"`
+	Uses int    `meddler:"uses,validate=min=0|max=100"`
+}
+
+func (elt *ValidatedTag) Validate() error {
+	if strings.HasPrefix(elt.Name, "_") {
+		return &ValidationError{Failures: []ValidationFailure{{Column: "name", Err: errors.New("must not start with _")}}}
+	}
+	if elt.Name == "banned" {
+		return errors.New("forbidden tag")
+	}
+	return nil
+}
+
+func TestValidate(t *testing.T) {
+	once.Do(setup)
+
+	// every failure is reported at once
+	elt := &ValidatedTag{Name: "", Uses: 101}
+	err := Insert(db, "tag", elt)
+	verr, ok := err.(*ValidationError)
+	if !ok {
+		t.Fatalf("Insert of invalid record: expected *ValidationError, got %v", err)
+	}
+	if len(verr.Failures) != 2 || verr.Failures[0].Rule != "required" || verr.Failures[1].Rule != "max" {
+		t.Errorf("Insert of invalid record: expected required and max failures, found %v", verr.Failures)
+	}
+	if !reflect.DeepEqual(verr.Columns(), []string{"name", "uses"}) {
+		t.Errorf("Insert of invalid record: expected name and uses to fail, found %v", verr.Columns())
+	}
+	if elt.ID != 0 {
+		t.Errorf("Insert of invalid record: expected no row to be inserted, found id %d", elt.ID)
+	}
+
+	elt = &ValidatedTag{Name: "golang", Uses: 1}
+	if err := Insert(db, "tag", elt); err != nil {
+		t.Fatalf("Insert error: %v", err)
+	}
+
+	// Validator failures are merged with the tag rules
+	elt.Name = "_golang12"
+	err = Update(db, "tag", elt)
+	if verr, ok = err.(*ValidationError); !ok {
+		t.Fatalf("Update of invalid record: expected *ValidationError, got %v", err)
+	}
+	if len(verr.Failures) != 2 || verr.Failures[0].Rule != "max" || verr.Failures[1].Rule != "" {
+		t.Errorf("Update of invalid record: expected max and Validator failures, found %v", verr.Failures)
+	}
+	expected := "meddler: validation failed: name: max: length must be at most 8; name: must not start with _"
+	if err.Error() != expected {
+		t.Errorf("Update of invalid record: expected %q, found %q", expected, err.Error())
+	}
+
+	elt.Name = "banned"
+	err = Save(db, "tag", elt)
+	if verr, ok = err.(*ValidationError); !ok || len(verr.Failures) != 1 || verr.Failures[0].Column != "" {
+		t.Errorf("Save of invalid record: expected a single record failure, got %v", err)
+	}
+
+	// only the columns being written are checked
+	elt.Name = ""
+	elt.Uses = 5
+	if err := UpdateColumns(db, "tag", elt, "uses"); err != nil {
+		t.Errorf("UpdateColumns error: %v", err)
+	}
+
+	// custom rules
+	RegisterRule("even", func(field interface{}, arg string) error {
+		if field.(int)%2 != 0 {
+			return errors.New("must be even")
+		}
+		return nil
+	})
+	type evenTag struct {
+		ID   int64 `meddler:"id,pk"`
+		Uses int   `meddler:"uses,validate=even"`
+	}
+	if err := validate(&evenTag{Uses: 3}, []string{"uses"}); err == nil {
+		t.Errorf("validate with custom rule: expected err, got nil")
+	}
+	if err := validate(&evenTag{Uses: 4}, []string{"uses"}); err != nil {
+		t.Errorf("validate with custom rule error: %v", err)
+	}
+
+	type unknownRule struct {
+		ID   int64 `meddler:"id,pk"`
+		Uses int   `meddler:"uses,validate=nosuchrule"`
+	}
+	if _, err := getFields(reflect.TypeOf(&unknownRule{})); err == nil {
+		t.Errorf("getFields with unknown rule: expected err, got nil")
+	}
+	db.Exec("delete from tag")
+}
+
+func TestSizeRule(t *testing.T) {
+	five := 5
+	tests := []struct {
+		field interface{}
+		arg   string
+		max   bool
+		ok    bool
+	}{
+		{"héllo", "5", true, true},
+		{"héllo!", "5", true, false},
+		{[]byte{1, 2}, "3", false, false},
+		{&five, "5", false, true},
+		{(*int)(nil), "5", false, true},
+		{2.5, "2", true, false},
+		{uint8(3), "3", true, true},
+		{"abc", "x", true, false},
+	}
+	for _, test := range tests {
+		err := sizeRule(test.field, test.arg, test.max)
+		if (err == nil) != test.ok {
+			t.Errorf("sizeRule(%v, %s, %v): expected ok=%v, got %v", test.field, test.arg, test.max, test.ok, err)
+		}
+	}
+}
+
+type ValidatedToken struct {
+	ID    string `meddler:"id,pk"`
+	Label string `meddler:"label,validate=required"`
+}
+
+var validatedTokenKeys int
+
+func (tok *ValidatedToken) GenerateKey() (interface{}, error) {
+	validatedTokenKeys++
+	return "generated", nil
+}
+
+type ValidatedSequenceTag struct {
+	ID   int64  `meddler:"id,pk,sequence=tag_seq"`
+	Name string `meddler:"name,validate=required"`
+}
+
+func TestValidateBeforeKeys(t *testing.T) {
+	once.Do(setup)
+
+	// no key is generated for an invalid record
+	tok := new(ValidatedToken)
+	var verr *ValidationError
+	if err := Insert(db, "token", tok); !errors.As(err, &verr) {
+		t.Errorf("Insert of invalid token: expected *ValidationError, got %v", err)
+	}
+	if validatedTokenKeys != 0 || tok.ID != "" {
+		t.Errorf("Insert of invalid token: expected no key, found %d calls and ID %q", validatedTokenKeys, tok.ID)
+	}
+
+	// and nothing is drawn from a sequence
+	fake := new(fakeDB)
+	sqlDB := fake.open()
+	defer sqlDB.Close()
+	if err := Oracle.Insert(sqlDB, "tag", new(ValidatedSequenceTag)); !errors.As(err, &verr) {
+		t.Errorf("Insert of invalid sequence tag: expected *ValidationError, got %v", err)
+	}
+	if len(fake.queries) != 0 {
+		t.Errorf("Insert of invalid sequence tag: expected no queries, found %q", fake.queries)
+	}
+
+	// a key is only kept once the row is written
+	tok.Label = "valid"
+	if err := Insert(db, "no_such_table", tok); err == nil {
+		t.Errorf("Insert into missing table: expected err, got nil")
+	}
+	if validatedTokenKeys != 1 || tok.ID != "" {
+		t.Errorf("failed Insert: expected the generated key to be dropped, found %d calls and ID %q", validatedTokenKeys, tok.ID)
+	}
+}